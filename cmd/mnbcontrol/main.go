@@ -5,6 +5,7 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	"github.com/mycreepy/mnbcontrol/internal/control"
@@ -22,16 +23,47 @@ var (
 	logReportCaller        = flag.Bool("logReportCaller", true, "log report caller")
 	logFormatterJSON       = flag.Bool("logFormatterJson", false, "log formatter json")
 	listenAddr             = flag.String("listenAddr", ":8000", "http server listen address")
+	publicURL              = flag.String("publicURL", "http://localhost:8000", "public url of the http server used for links")
+	trustedProxies         = flag.String("trustedProxies", "", "comma separated list of trusted reverse proxy ips or cidrs")
 	locationName           = flag.String("locationName", "nbg1", "location name")
 	networkIDs             = flag.String("networkIDs", "", "comma separated list of network ids")
 	sshKeyIDs              = flag.String("sshKeyIDs", "", "comma separated list if ssh key ids")
-	dnsZoneID              = flag.Int64("dnsZoneID", 0, "dns zone id, can be zero for disabling dns support")
+	dnsProvider            = flag.String("dnsProvider", "hetzner", "dns provider (hetzner, rfc2136, cloudflare or none)")
+	dnsZoneID              = flag.Int64("dnsZoneID", 0, "hetzner dns zone id, can be zero for disabling dns support")
+	dnsZoneName            = flag.String("dnsZoneName", "mnbr.eu", "dns zone name")
+	dnsRecordSuffix        = flag.String("dnsRecordSuffix", ".svc", "suffix appended to the server name for its dns record")
+	dnsRecordTTL           = flag.Int("dnsRecordTTL", 300, "default ttl of dns records in seconds")
+	dnsInternalSuffix      = flag.String("dnsInternalSuffix", ".internal", "suffix appended to the server name for its internal dns record")
+	cloudflareZoneID       = flag.String("cloudflareZoneID", "", "cloudflare zone id")
+	rfc2136Server          = flag.String("rfc2136Server", "", "address of the name server accepting rfc2136 updates, e.g. 127.0.0.1:53")
+	rfc2136TSIGKeyName     = flag.String("rfc2136TSIGKeyName", "", "tsig key name for rfc2136 updates, can be empty")
+	rfc2136TSIGAlgorithm   = flag.String("rfc2136TSIGAlgorithm", "hmac-sha256", "tsig algorithm for rfc2136 updates")
 	discordCallback        = flag.String("discordCallback", "http://localhost:8000/auth/callback?provider=discord", "discord oauth callback url")
 	discordGuildID         = flag.String("discordGuildID", "", "discord guild id for authorization")
 	discordChannelID       = flag.String("discordChannelID", "", "discord channel id for user interaction")
 	discordAdminRoleID     = flag.String("discordAdminRoleID", "", "discord role id for admin authorization")
 	discordUserRoleID      = flag.String("discordUserRoleID", "", "discord role id for user authorization")
 	discordPowerUserRoleID = flag.String("discordPowerUserRoleID", "", "discord role id for power user authorization")
+	discordAuditChannelID  = flag.String("discordAuditChannelID", "", "discord channel id for audit messages, can be empty")
+	reconcileInterval      = flag.Duration("reconcileInterval", 15*time.Minute, "interval for reconciling ttl timers with the running servers")
+	healthCheckInterval    = flag.Duration("healthCheckInterval", time.Minute, "interval between health checks of running servers, zero disables health checks")
+	terminationWorkers     = flag.Int("terminationWorkers", 3, "number of expired servers terminated in parallel")
+	terminationAlertAfter  = flag.Int("terminationAlertAfter", 3, "alert admins after this many failed terminations of a server, zero disables alerts")
+	alertWebhookURL        = flag.String("alertWebhookURL", "", "discord compatible webhook url for admin alerts, can be empty")
+	stateFile              = flag.String("stateFile", "mnbcontrol-state.json", "path to the state file, empty keeps the state in memory only")
+	confirmCostAbove       = flag.Float64("confirmCostAbove", 0, "projected cost of a server run above which a confirmation is required, zero disables confirmations")
+	budgetsFile            = flag.String("budgetsFile", "", "path to the monthly budgets file, can be empty")
+	maxServers             = flag.Int("maxServers", 0, "maximum number of concurrently running managed servers, further starts are queued, zero disables the limit")
+	approvalTimeout        = flag.Duration("approvalTimeout", 30*time.Minute, "time admins and power users have to approve a start request of a user")
+	voteQuorum             = flag.Int("voteQuorum", 0, "number of votes needed to start or extend a server by vote, zero disables voting")
+	voteWindow             = flag.Duration("voteWindow", 15*time.Minute, "time a vote to start or extend a server is open")
+	watchEvents            = flag.Bool("watchEvents", false, "start the services referenced by discord scheduled events")
+	eventLeadTime          = flag.Duration("eventLeadTime", 15*time.Minute, "time before the start of a scheduled event its service is started")
+	eventGracePeriod       = flag.Duration("eventGracePeriod", time.Hour, "time after the end of a scheduled event its service keeps running")
+	restrictToOwner        = flag.Bool("restrictToOwner", false, "allow power users to only stop, reboot and extend servers they own or started")
+	quotasFile             = flag.String("quotasFile", "", "path to the per-role and per-user quotas file, can be empty")
+	rolesFile              = flag.String("rolesFile", "", "path to the per-role server type and location allowlist file, can be empty")
+	servicesFile           = flag.String("servicesFile", "", "path to the per-service configuration file, can be empty")
 )
 
 func init() {
@@ -81,17 +113,94 @@ func main() {
 		}
 	}
 
+	var proxies []string
+
+	if len(*trustedProxies) > 0 {
+		proxies = strings.Split(*trustedProxies, ",")
+	}
+
+	var services map[string]*control.ServiceConfig
+
+	if len(*servicesFile) > 0 {
+		var err error
+		services, err = control.LoadServiceConfigs(*servicesFile)
+		if err != nil {
+			logrus.Fatalf("failed to load services: %s", err)
+		}
+	}
+
+	var budget *control.BudgetConfig
+
+	if len(*budgetsFile) > 0 {
+		var err error
+		budget, err = control.LoadBudgetConfig(*budgetsFile)
+		if err != nil {
+			logrus.Fatalf("failed to load budgets: %s", err)
+		}
+	}
+
+	var quotas *control.QuotaConfig
+
+	if len(*quotasFile) > 0 {
+		var err error
+		quotas, err = control.LoadQuotaConfig(*quotasFile)
+		if err != nil {
+			logrus.Fatalf("failed to load quotas: %s", err)
+		}
+	}
+
+	var rolePolicies map[string]*control.RolePolicy
+
+	if len(*rolesFile) > 0 {
+		var err error
+		rolePolicies, err = control.LoadRolePolicies(*rolesFile)
+		if err != nil {
+			logrus.Fatalf("failed to load roles: %s", err)
+		}
+	}
+
 	ctrl, err := control.New(&control.Config{
 		ListenAddr:             *listenAddr,
+		PublicURL:              *publicURL,
+		TrustedProxies:         proxies,
 		Location:               &hcloud.Location{Name: *locationName},
 		Networks:               networks,
 		SSHKeys:                sshKeys,
+		DNSProvider:            *dnsProvider,
 		DNSZoneID:              *dnsZoneID,
+		DNSZoneName:            *dnsZoneName,
+		DNSRecordSuffix:        *dnsRecordSuffix,
+		DNSRecordTTL:           *dnsRecordTTL,
+		DNSInternalSuffix:      *dnsInternalSuffix,
+		CloudflareZoneID:       *cloudflareZoneID,
+		RFC2136Server:          *rfc2136Server,
+		RFC2136TSIGKeyName:     *rfc2136TSIGKeyName,
+		RFC2136TSIGAlgorithm:   *rfc2136TSIGAlgorithm,
 		DiscordGuildID:         *discordGuildID,
 		DiscordChannelID:       *discordChannelID,
 		DiscordAdminRoleID:     *discordAdminRoleID,
 		DiscordUserRoleID:      *discordUserRoleID,
 		DiscordPowerUserRoleID: *discordPowerUserRoleID,
+		DiscordAuditChannelID:  *discordAuditChannelID,
+		ReconcileInterval:      *reconcileInterval,
+		HealthCheckInterval:    *healthCheckInterval,
+		TerminationWorkers:     *terminationWorkers,
+		TerminationAlertAfter:  *terminationAlertAfter,
+		AlertWebhookURL:        *alertWebhookURL,
+		StateFile:              *stateFile,
+		Budget:                 budget,
+		ConfirmCostAbove:       *confirmCostAbove,
+		RolePolicies:           rolePolicies,
+		Quotas:                 quotas,
+		MaxServers:             *maxServers,
+		RestrictToOwner:        *restrictToOwner,
+		ApprovalTimeout:        *approvalTimeout,
+		VoteQuorum:             *voteQuorum,
+		VoteWindow:             *voteWindow,
+		WatchEvents:            *watchEvents,
+		EventLeadTime:          *eventLeadTime,
+		EventGracePeriod:       *eventGracePeriod,
+		Services:               services,
 	})
 	if err != nil {
 		logrus.Fatalf("failed to create control: %s", err)
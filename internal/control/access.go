@@ -0,0 +1,103 @@
+package control
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ActionAll    = "*"
+	ActionView   = "view"
+	ActionStart  = "start"
+	ActionStop   = "stop"
+	ActionExtend = "extend"
+	ActionReboot = "reboot"
+	ActionType   = "type"
+	ActionAllow  = "allow"
+	ActionRCON   = "rcon"
+)
+
+// AccessRule grants the listed actions on a service to members of the roles and to the users.
+type AccessRule struct {
+	RoleIDs []string `json:"roleIDs,omitempty"`
+	UserIDs []string `json:"userIDs,omitempty"`
+	Actions []string `json:"actions"`
+}
+
+// memberMayAccess reports whether the member may take the action on the service. Services without access
+// rules are open to everyone with the role required for the action and admins may access every service.
+func (control *Control) memberMayAccess(member *discordgo.Member, serviceName, action string) bool {
+	rules := control.serviceConfig(serviceName).Access
+	if len(rules) == 0 || memberHasRole(member, control.Config.DiscordAdminRoleID) {
+		return true
+	}
+
+	for _, rule := range rules {
+		if !slices.Contains(rule.Actions, action) && !slices.Contains(rule.Actions, ActionAll) {
+			continue
+		}
+
+		if slices.Contains(rule.UserIDs, member.User.ID) || memberHasRole(member, rule.RoleIDs...) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// viewFilter returns a filter of the services the member may view.
+func (control *Control) viewFilter(member *discordgo.Member) func(serviceName string) bool {
+	return func(serviceName string) bool {
+		return control.memberMayAccess(member, serviceName, ActionView)
+	}
+}
+
+func (control *Control) checkAccess(member *discordgo.Member, serviceName, action string) error {
+	if !control.memberMayAccess(member, serviceName, action) {
+		return fmt.Errorf("%w: you may not %s server %s", ErrNotAllowed, action, serviceName)
+	}
+
+	return nil
+}
+
+// RequireRoles aborts requests of members without any of the roles.
+func (control *Control) RequireRoles(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		member, ok := ctx.MustGet(ContextKeyMember).(*discordgo.Member)
+		if !ok || !memberHasRole(member, roles...) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+				"forbidden: permission check failed",
+			})
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// RequireAccess aborts requests of members who may not take the action on the service named in the path.
+func (control *Control) RequireAccess(action string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		member, ok := ctx.MustGet(ContextKeyMember).(*discordgo.Member)
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+				"forbidden: permission check failed",
+			})
+			return
+		}
+
+		err := control.checkAccess(member, ctx.Param("name"), action)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+				err.Error(),
+			})
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,60 @@
+package control
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestMemberMayAccess(t *testing.T) {
+	control := &Control{Config: &Config{
+		DiscordAdminRoleID: testAdminRoleID,
+		Services: map[string]*ServiceConfig{
+			"minecraft": {Access: []AccessRule{
+				{RoleIDs: []string{"players"}, Actions: []string{ActionView, ActionStart}},
+				{UserIDs: []string{"moderator"}, Actions: []string{ActionAll}},
+			}},
+			"factorio": {},
+		},
+	}}
+
+	tests := []struct {
+		name    string
+		userID  string
+		roles   []string
+		service string
+		action  string
+		allowed bool
+	}{
+		{name: "service without rules", userID: "someone", service: "factorio", action: ActionStop, allowed: true},
+		{name: "unknown service", userID: "someone", service: "valheim", action: ActionStart, allowed: true},
+		{name: "admin", userID: "someone", roles: []string{testAdminRoleID}, service: "minecraft", action: ActionStop, allowed: true},
+		{name: "role with the action", userID: "someone", roles: []string{"players"}, service: "minecraft", action: ActionStart, allowed: true},
+		{name: "role without the action", userID: "someone", roles: []string{"players"}, service: "minecraft", action: ActionStop},
+		{name: "user with all actions", userID: "moderator", service: "minecraft", action: ActionRCON, allowed: true},
+		{name: "no matching rule", userID: "someone", roles: []string{testPowerRoleID}, service: "minecraft", action: ActionView},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			member := &discordgo.Member{User: &discordgo.User{ID: tt.userID}, Roles: tt.roles}
+
+			if allowed := control.memberMayAccess(member, tt.service, tt.action); allowed != tt.allowed {
+				t.Errorf("expected %t, got %t", tt.allowed, allowed)
+			}
+
+			err := control.checkAccess(member, tt.service, tt.action)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrNotAllowed) {
+				t.Errorf("expected %v, got %v", ErrNotAllowed, err)
+			}
+
+			if visible := control.viewFilter(member)(tt.service); visible != control.memberMayAccess(member, tt.service, ActionView) {
+				t.Errorf("expected the view filter to match the view access, got %t", visible)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package control
+
+import (
+	"net"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// ipv6HostAddress returns the first host address (::1) of the /64 network hetzner assigns to a server.
+func ipv6HostAddress(network net.IP) net.IP {
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, network.To16())
+	ip[net.IPv6len-1] |= 1
+
+	return ip
+}
+
+// serverIPv4 returns the public ipv4 address of the server, it is empty for ipv6 only servers.
+func serverIPv4(server *hcloud.Server) string {
+	if server.PublicNet.IPv4.IsUnspecified() {
+		return ""
+	}
+
+	return server.PublicNet.IPv4.IP.String()
+}
+
+// serverIPv6 returns the public ipv6 host address of the server.
+func serverIPv6(server *hcloud.Server) string {
+	if server.PublicNet.IPv6.IsUnspecified() {
+		return ""
+	}
+
+	network := server.PublicNet.IPv6.IP
+	if server.PublicNet.IPv6.Network != nil {
+		network = server.PublicNet.IPv6.Network.IP
+	}
+
+	return ipv6HostAddress(network).String()
+}
+
+// serverAddress returns the address for connecting to the server, preferring ipv4.
+func serverAddress(server *hcloud.Server) string {
+	if ipv4 := serverIPv4(server); ipv4 != "" {
+		return ipv4
+	}
+
+	return serverIPv6(server)
+}
+
+// serverDNSPtr returns the reverse dns entry of the server.
+func serverDNSPtr(server *hcloud.Server) string {
+	if server.PublicNet.IPv4.DNSPtr != "" {
+		return server.PublicNet.IPv4.DNSPtr
+	}
+
+	return server.PublicNet.IPv6.DNSPtr[serverIPv6(server)]
+}
+
+func setServerDNSPtr(server *hcloud.Server, dnsPtr string) {
+	if ipv4 := serverIPv4(server); ipv4 != "" {
+		server.PublicNet.IPv4.DNSPtr = dnsPtr
+		return
+	}
+
+	server.PublicNet.IPv6.DNSPtr = map[string]string{serverIPv6(server): dnsPtr}
+}
+
+func valueOrNA(value string) string {
+	if value == "" {
+		return "n/a"
+	}
+
+	return value
+}
@@ -0,0 +1,84 @@
+package control
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestIPv6HostAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		network  string
+		expected string
+	}{
+		{name: "network", network: "2a01:4f8:c17:1a2b::", expected: "2a01:4f8:c17:1a2b::1"},
+		{name: "host address", network: "2a01:4f8:c17:1a2b::1", expected: "2a01:4f8:c17:1a2b::1"},
+		{name: "full network", network: "2a01:4f8:c17:1a2b:ffff:ffff:ffff:fffe", expected: "2a01:4f8:c17:1a2b:ffff:ffff:ffff:ffff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			network := net.ParseIP(tt.network)
+
+			ip := ipv6HostAddress(network)
+			if ip.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, ip)
+			}
+
+			if network.String() != net.ParseIP(tt.network).String() {
+				t.Errorf("expected the network %s to be unchanged, got %s", tt.network, network)
+			}
+		})
+	}
+}
+
+func TestServerAddress(t *testing.T) {
+	_, ipv6Network, _ := net.ParseCIDR("2a01:4f8:c17:1a2b::/64")
+
+	tests := []struct {
+		name      string
+		publicNet hcloud.ServerPublicNet
+		ipv4      string
+		ipv6      string
+		address   string
+	}{
+		{
+			name: "dual stack",
+			publicNet: hcloud.ServerPublicNet{
+				IPv4: hcloud.ServerPublicNetIPv4{IP: net.ParseIP("192.0.2.1")},
+				IPv6: hcloud.ServerPublicNetIPv6{IP: ipv6Network.IP, Network: ipv6Network},
+			},
+			ipv4:    "192.0.2.1",
+			ipv6:    "2a01:4f8:c17:1a2b::1",
+			address: "192.0.2.1",
+		},
+		{
+			name: "ipv6 only",
+			publicNet: hcloud.ServerPublicNet{
+				IPv6: hcloud.ServerPublicNetIPv6{IP: ipv6Network.IP, Network: ipv6Network},
+			},
+			ipv6:    "2a01:4f8:c17:1a2b::1",
+			address: "2a01:4f8:c17:1a2b::1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &hcloud.Server{PublicNet: tt.publicNet}
+
+			if ipv4 := serverIPv4(server); ipv4 != tt.ipv4 {
+				t.Errorf("expected ipv4 %q, got %q", tt.ipv4, ipv4)
+			}
+
+			if ipv6 := serverIPv6(server); ipv6 != tt.ipv6 {
+				t.Errorf("expected ipv6 %q, got %q", tt.ipv6, ipv6)
+			}
+
+			if address := serverAddress(server); address != tt.address {
+				t.Errorf("expected address %q, got %q", tt.address, address)
+			}
+		})
+	}
+}
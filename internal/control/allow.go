@@ -0,0 +1,301 @@
+package control
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// temporary rules carry their expiry in the description, so they survive restarts of mnbcontrol
+	allowRuleDescriptionPrefix = "mnbcontrol-allow:"
+	defaultAllowDuration       = time.Hour
+	allowLinkTTL               = 10 * time.Minute
+	allowCleanupInterval       = time.Minute
+)
+
+// allowConfirmPage posts back to the link it was served from.
+const allowConfirmPage = `<!DOCTYPE html>
+<html>
+<head><title>mnbcontrol</title></head>
+<body>
+<form method="post">
+<p>Allow your IP on the server?</p>
+<button type="submit">Allow</button>
+</form>
+</body>
+</html>
+`
+
+var (
+	errInvalidAllowLink = errors.New("the link is invalid or has expired")
+)
+
+type allowLink struct {
+	serviceName string
+	userID      string
+	expires     time.Time
+}
+
+// allowLinks holds the one-time links handed out by the bot.
+type allowLinks struct {
+	mutex sync.Mutex
+	links map[string]allowLink
+}
+
+func newAllowLinks() *allowLinks {
+	return &allowLinks{links: make(map[string]allowLink)}
+}
+
+func (links *allowLinks) create(serviceName, userID string) (string, error) {
+	buf := make([]byte, 32)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	token := hex.EncodeToString(buf)
+
+	links.mutex.Lock()
+	defer links.mutex.Unlock()
+
+	links.links[token] = allowLink{
+		serviceName: serviceName,
+		userID:      userID,
+		expires:     time.Now().Add(allowLinkTTL),
+	}
+
+	return token, nil
+}
+
+// valid reports whether the token belongs to an unexpired link without invalidating it.
+func (links *allowLinks) valid(token string) bool {
+	links.mutex.Lock()
+	defer links.mutex.Unlock()
+
+	link, ok := links.links[token]
+
+	return ok && time.Now().Before(link.expires)
+}
+
+// redeem returns the link of the token and invalidates it.
+func (links *allowLinks) redeem(token string) (allowLink, bool) {
+	links.mutex.Lock()
+	defer links.mutex.Unlock()
+
+	link, ok := links.links[token]
+	delete(links.links, token)
+
+	if !ok || time.Now().After(link.expires) {
+		return allowLink{}, false
+	}
+
+	return link, true
+}
+
+// restore puts a redeemed link back, so it can be used again after a failure.
+func (links *allowLinks) restore(token string, link allowLink) {
+	links.mutex.Lock()
+	defer links.mutex.Unlock()
+
+	links.links[token] = link
+}
+
+func (links *allowLinks) removeExpired() {
+	links.mutex.Lock()
+	defer links.mutex.Unlock()
+
+	now := time.Now()
+
+	for token, link := range links.links {
+		if now.After(link.expires) {
+			delete(links.links, token)
+		}
+	}
+}
+
+// allowIP opens the restricted ports of the service firewall for the ip until the duration has passed.
+func (control *Control) allowIP(ctx context.Context, actor, serviceName, ipStr string, duration time.Duration) (*time.Time, error) {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip address %s", ipStr)
+	}
+
+	svc := control.serviceConfig(serviceName)
+
+	allowDuration := defaultAllowDuration
+	if svc.AllowDuration != "" {
+		var err error
+		allowDuration, err = time.ParseDuration(svc.AllowDuration)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse allow duration: %s", err)
+		}
+	}
+
+	if duration <= 0 || duration > allowDuration {
+		duration = allowDuration
+	}
+
+	var restrictedPorts []PortConfig
+
+	for _, port := range svc.Ports {
+		if port.Restricted {
+			restrictedPorts = append(restrictedPorts, port)
+		}
+	}
+
+	if len(restrictedPorts) == 0 {
+		return nil, fmt.Errorf("service %s has no restricted ports", serviceName)
+	}
+
+	unlock := control.firewallLocks.lock(serviceName)
+	defer unlock()
+
+	firewall, err := control.getFirewall(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	if firewall == nil {
+		return nil, fmt.Errorf("service %s has no managed firewall", serviceName)
+	}
+
+	bits := 8 * net.IPv6len
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 8 * net.IPv4len
+	}
+
+	sourceNet := net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	expires := time.Now().Add(duration)
+
+	// previous rules of the same ip are replaced to refresh their expiry
+	rules := filterFirewallRules(firewall.Rules, func(rule hcloud.FirewallRule) bool {
+		return !isAllowRule(rule) || len(rule.SourceIPs) != 1 || rule.SourceIPs[0].String() != sourceNet.String()
+	})
+
+	for _, port := range restrictedPorts {
+		rule := hcloud.FirewallRule{
+			Direction:   hcloud.FirewallRuleDirectionIn,
+			Protocol:    hcloud.FirewallRuleProtocol(strings.ToLower(port.Protocol)),
+			SourceIPs:   []net.IPNet{sourceNet},
+			Description: new(allowRuleDescriptionPrefix + strconv.FormatInt(expires.Unix(), 10)),
+		}
+		if port.Port != "" {
+			rule.Port = new(port.Port)
+		}
+		rules = append(rules, rule)
+	}
+
+	err = control.setFirewallRules(ctx, firewall, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	control.audit(actor, "allow", serviceName, fmt.Sprintf("%s until %s", ip, expires.Format(time.RFC3339)))
+
+	return &expires, nil
+}
+
+// removeExpiredAllowRules removes temporary rules from all managed firewalls once they expired.
+func (control *Control) removeExpiredAllowRules(ctx context.Context) {
+	control.allowLinks.removeExpired()
+
+	firewalls, err := control.hclient.Firewall.AllWithOpts(ctx, hcloud.FirewallListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: fmt.Sprintf("%s=%s", LabelManagedBy, LabelValueMangedByControl)},
+	})
+	if err != nil {
+		log.Errorf("firewall error: failed to list firewalls: %s", err)
+		return
+	}
+
+	for _, firewall := range firewalls {
+		err = control.removeExpiredFirewallRules(ctx, firewall.Labels[LabelService])
+		if err != nil {
+			log.Errorf("firewall error: failed to remove expired rules: %s", err)
+		}
+	}
+}
+
+// removeExpiredFirewallRules removes the expired temporary rules from the firewall of the service.
+func (control *Control) removeExpiredFirewallRules(ctx context.Context, serviceName string) error {
+	unlock := control.firewallLocks.lock(serviceName)
+	defer unlock()
+
+	// the firewall is read again under the lock, its rules might have changed since it was listed
+	firewall, err := control.getFirewall(ctx, serviceName)
+	if err != nil || firewall == nil {
+		return err
+	}
+
+	rules := filterFirewallRules(firewall.Rules, func(rule hcloud.FirewallRule) bool {
+		return !isExpiredAllowRule(rule)
+	})
+
+	if len(rules) == len(firewall.Rules) {
+		return nil
+	}
+
+	err = control.setFirewallRules(ctx, firewall, rules)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("removed %d expired allow rules from firewall %s", len(firewall.Rules)-len(rules), firewall.Name)
+
+	return nil
+}
+
+func (control *Control) setFirewallRules(ctx context.Context, firewall *hcloud.Firewall, rules []hcloud.FirewallRule) error {
+	actions, _, err := control.hclient.Firewall.SetRules(ctx, firewall, hcloud.FirewallSetRulesOpts{Rules: rules})
+	if err != nil {
+		return fmt.Errorf("failed to set rules of firewall %s: %s", firewall.Name, err)
+	}
+
+	err = control.hclient.Action.WaitFor(ctx, actions...)
+	if err != nil {
+		return fmt.Errorf("failed to set rules of firewall %s: %s", firewall.Name, err)
+	}
+
+	return nil
+}
+
+func filterFirewallRules(rules []hcloud.FirewallRule, keep func(rule hcloud.FirewallRule) bool) []hcloud.FirewallRule {
+	var filtered []hcloud.FirewallRule
+
+	for _, rule := range rules {
+		if keep(rule) {
+			filtered = append(filtered, rule)
+		}
+	}
+
+	return filtered
+}
+
+func isAllowRule(rule hcloud.FirewallRule) bool {
+	return rule.Description != nil && strings.HasPrefix(*rule.Description, allowRuleDescriptionPrefix)
+}
+
+func isExpiredAllowRule(rule hcloud.FirewallRule) bool {
+	if !isAllowRule(rule) {
+		return false
+	}
+
+	expires, err := strconv.ParseInt(strings.TrimPrefix(*rule.Description, allowRuleDescriptionPrefix), 10, 64)
+	if err != nil {
+		return true
+	}
+
+	return time.Now().After(time.Unix(expires, 0))
+}
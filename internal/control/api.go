@@ -4,9 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
+	"github.com/bwmarrin/discordgo"
 	"github.com/gin-gonic/gin"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
 
 type APIError struct {
@@ -16,12 +19,25 @@ type APIError struct {
 type CreateNewServerRequest struct {
 	ServerName string `json:"serverName"`
 	ServerType string `json:"serverType"`
+	Location   string `json:"location,omitempty"`
 	TTL        string `json:"ttl"`
+	Confirm    bool   `json:"confirm,omitempty"`
+	// slotReserved is set for queued requests which already hold a server slot
+	slotReserved bool
 }
 
 type StartServerRequest struct {
 	ServerName string `json:"serverName"`
 	TTL        string `json:"ttl"`
+	Confirm    bool   `json:"confirm,omitempty"`
+	// slotReserved is set for queued requests which already hold a server slot
+	slotReserved bool
+}
+
+// ConfirmationRequired is returned with 428 Precondition Required, the request must be repeated with confirm=true.
+type ConfirmationRequired struct {
+	APIError
+	Preview *CostConfirmationError `json:"preview"`
 }
 
 type ExtendServerRequest struct {
@@ -35,6 +51,20 @@ type ChangeServerTypeRequest struct {
 	ServerType string `json:"serverType"`
 }
 
+type RCONRequest struct {
+	ServerName string `json:"serverName"`
+	Command    string `json:"command"`
+}
+
+type AllowRequest struct {
+	Duration string `json:"duration,omitempty"`
+}
+
+type AllowResponse struct {
+	IP      string    `json:"ip"`
+	Expires time.Time `json:"expires"`
+}
+
 func (control *Control) ListServers(ctx *gin.Context) {
 	managedServers, err := control.listServers(ctx)
 	if err != nil {
@@ -43,7 +73,14 @@ func (control *Control) ListServers(ctx *gin.Context) {
 		})
 		return
 	}
-	ctx.JSON(http.StatusOK, managedServers)
+	member := ctx.MustGet(ContextKeyMember).(*discordgo.Member)
+	visibleServers := []*hcloud.Server{}
+	for _, server := range managedServers {
+		if control.memberMayAccess(member, server.Name, ActionView) {
+			visibleServers = append(visibleServers, server)
+		}
+	}
+	ctx.JSON(http.StatusOK, visibleServers)
 }
 
 func (control *Control) NewServer(ctx *gin.Context) {
@@ -55,8 +92,38 @@ func (control *Control) NewServer(ctx *gin.Context) {
 		})
 		return
 	}
+	if ctx.Query("confirm") == "true" {
+		req.Confirm = true
+	}
 
-	server, err := control.newServer(ctx, req)
+	err = control.checkAccess(ctx.MustGet(ContextKeyMember).(*discordgo.Member), req.ServerName, ActionStart)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			err.Error(),
+		})
+		return
+	}
+
+	server, err := control.newServer(ctx, ctx.GetString(ContextKeyUserID), req)
+	var confirmErr *CostConfirmationError
+	if errors.As(err, &confirmErr) {
+		ctx.AbortWithStatusJSON(http.StatusPreconditionRequired, ConfirmationRequired{
+			APIError{confirmErr.Error()},
+			confirmErr,
+		})
+		return
+	}
+	var queuedErr *QueuedError
+	if errors.As(err, &queuedErr) {
+		ctx.JSON(http.StatusAccepted, queuedErr.Entry)
+		return
+	}
+	if errors.Is(err, ErrNotAllowed) || errors.Is(err, ErrQuotaExceeded) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			fmt.Errorf("failed to create new server: %s", err).Error(),
+		})
+		return
+	}
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
 			fmt.Errorf("failed to create new server: %s", err).Error(),
@@ -64,7 +131,7 @@ func (control *Control) NewServer(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, server)
+	ctx.JSON(control.readinessStatus(server), server)
 }
 
 func (control *Control) StartServer(ctx *gin.Context) {
@@ -84,14 +151,36 @@ func (control *Control) StartServer(ctx *gin.Context) {
 		return
 	}
 	req.ServerName = serverName
-	server, err := control.startServer(ctx, req)
+	if ctx.Query("confirm") == "true" {
+		req.Confirm = true
+	}
+	server, err := control.startServer(ctx, ctx.GetString(ContextKeyUserID), req)
+	var confirmErr *CostConfirmationError
+	if errors.As(err, &confirmErr) {
+		ctx.AbortWithStatusJSON(http.StatusPreconditionRequired, ConfirmationRequired{
+			APIError{confirmErr.Error()},
+			confirmErr,
+		})
+		return
+	}
+	var queuedErr *QueuedError
+	if errors.As(err, &queuedErr) {
+		ctx.JSON(http.StatusAccepted, queuedErr.Entry)
+		return
+	}
+	if errors.Is(err, ErrQuotaExceeded) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			fmt.Errorf("failed to start server: %s", err).Error(),
+		})
+		return
+	}
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
 			fmt.Errorf("failed to start server: %s", err).Error(),
 		})
 		return
 	}
-	ctx.JSON(http.StatusCreated, server)
+	ctx.JSON(control.readinessStatus(server), server)
 }
 
 func (control *Control) TerminateServer(ctx *gin.Context) {
@@ -102,7 +191,39 @@ func (control *Control) TerminateServer(ctx *gin.Context) {
 		})
 		return
 	}
-	err := control.terminateServer(ctx, serverName)
+	err := control.checkServerOwner(ctx, ctx.GetString(ContextKeyUserID), serverName)
+	if errors.Is(err, ErrNotOwner) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			err.Error(),
+		})
+		return
+	}
+	err = control.terminateServer(ctx, serverName)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			err.Error(),
+		})
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+func (control *Control) DestroyService(ctx *gin.Context) {
+	serviceName, ok := ctx.Params.Get("name")
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+			errors.New("missing name parameter").Error(),
+		})
+		return
+	}
+	control.audit(ctx.GetString(ContextKeyUserID), "destroy", serviceName, "")
+	err := control.destroyService(ctx, serviceName)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
 			err.Error(),
@@ -120,7 +241,20 @@ func (control *Control) RebootServer(ctx *gin.Context) {
 		})
 		return
 	}
-	err := control.rebootServer(ctx, serverName)
+	err := control.checkServerOwner(ctx, ctx.GetString(ContextKeyUserID), serverName)
+	if errors.Is(err, ErrNotOwner) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			err.Error(),
+		})
+		return
+	}
+	err = control.rebootServer(ctx, serverName)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
 			err.Error(),
@@ -148,7 +282,27 @@ func (control *Control) ExtendServer(ctx *gin.Context) {
 	}
 	req.ServerName = serverName
 
-	newTTL, err := control.extendServer(ctx, req)
+	err = control.checkServerOwner(ctx, ctx.GetString(ContextKeyUserID), serverName)
+	if errors.Is(err, ErrNotOwner) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			err.Error(),
+		})
+		return
+	}
+
+	newTTL, err := control.extendServer(ctx, ctx.GetString(ContextKeyUserID), req)
+	if errors.Is(err, ErrQuotaExceeded) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			fmt.Errorf("failed extend server %s: %s", serverName, err).Error(),
+		})
+		return
+	}
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
 			fmt.Errorf("failed extend server %s: %s", serverName, err).Error(),
@@ -181,7 +335,13 @@ func (control *Control) ChangeServerType(ctx *gin.Context) {
 	}
 	req.ServerName = serverName
 
-	err = control.changeServerType(ctx, req)
+	err = control.changeServerType(ctx, ctx.GetString(ContextKeyUserID), req)
+	if errors.Is(err, ErrNotAllowed) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			fmt.Errorf("failed to change type of server %s: %s", serverName, err).Error(),
+		})
+		return
+	}
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
 			fmt.Errorf("failed extend server %s: %s", serverName, err).Error(),
@@ -191,3 +351,414 @@ func (control *Control) ChangeServerType(ctx *gin.Context) {
 
 	ctx.Status(http.StatusOK)
 }
+
+func (control *Control) ExecuteRCON(ctx *gin.Context) {
+	serverName, ok := ctx.Params.Get("name")
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+			errors.New("missing name parameter").Error(),
+		})
+		return
+	}
+	var req RCONRequest
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+			fmt.Errorf("failed to bind request: %s", err).Error(),
+		})
+		return
+	}
+	req.ServerName = serverName
+
+	roles := []string{control.Config.DiscordAdminRoleID}
+	if rconConfig := control.serviceConfig(serverName).RCON; rconConfig != nil {
+		roles = append(roles, rconConfig.RoleIDs...)
+	}
+	if !memberHasRole(ctx.MustGet(ContextKeyMember).(*discordgo.Member), roles...) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			"forbidden: permission check failed",
+		})
+		return
+	}
+
+	output, err := control.executeRCON(ctx, ctx.GetString(ContextKeyUserID), req)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			fmt.Errorf("failed to execute rcon command on server %s: %s", serverName, err).Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		Output string `json:"output"`
+	}{
+		output,
+	})
+}
+
+func (control *Control) GetServerHealth(ctx *gin.Context) {
+	serverName, ok := ctx.Params.Get("name")
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+			errors.New("missing name parameter").Error(),
+		})
+		return
+	}
+
+	status := control.health.status(serverName)
+	if status == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, APIError{
+			fmt.Errorf("no health status recorded for server %s", serverName).Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, status)
+}
+
+func (control *Control) AllowClientIP(ctx *gin.Context) {
+	serverName, ok := ctx.Params.Get("name")
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+			errors.New("missing name parameter").Error(),
+		})
+		return
+	}
+	var req AllowRequest
+	// the body is optional, the duration defaults to the allow duration of the service
+	if ctx.Request.ContentLength > 0 {
+		err := ctx.ShouldBindJSON(&req)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+				fmt.Errorf("failed to bind request: %s", err).Error(),
+			})
+			return
+		}
+	}
+	var duration time.Duration
+	if req.Duration != "" {
+		var err error
+		duration, err = time.ParseDuration(req.Duration)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+				fmt.Errorf("failed to parse duration: %s", err).Error(),
+			})
+			return
+		}
+	}
+
+	clientIP := ctx.ClientIP()
+
+	expires, err := control.allowIP(ctx, ctx.GetString(ContextKeyUserID), serverName, clientIP, duration)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			fmt.Errorf("failed to allow ip %s for server %s: %s", clientIP, serverName, err).Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, AllowResponse{clientIP, *expires})
+}
+
+// AllowByLink redeems a one-time link handed out by the bot, it is not behind the api authorization.
+// ConfirmAllowLink only renders a page which redeems the link with a POST, so link previews can not use it up.
+func (control *Control) ConfirmAllowLink(ctx *gin.Context) {
+	if !control.allowLinks.valid(ctx.Param("token")) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, APIError{
+			errInvalidAllowLink.Error(),
+		})
+		return
+	}
+
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(allowConfirmPage))
+}
+
+func (control *Control) AllowByLink(ctx *gin.Context) {
+	// the link is redeemed right away so it can not be used twice concurrently, and restored if allowing fails
+	token := ctx.Param("token")
+	link, ok := control.allowLinks.redeem(token)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, APIError{
+			errInvalidAllowLink.Error(),
+		})
+		return
+	}
+
+	// the member might have lost access since the link was created
+	member, err := control.guildMember(link.userID)
+	if err != nil {
+		control.allowLinks.restore(token, link)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			err.Error(),
+		})
+		return
+	}
+	if member == nil || !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			"forbidden: permission check failed",
+		})
+		return
+	}
+	err = control.checkAccess(member, link.serviceName, ActionAllow)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			err.Error(),
+		})
+		return
+	}
+
+	clientIP := ctx.ClientIP()
+
+	expires, err := control.allowIP(ctx, link.userID, link.serviceName, clientIP, 0)
+	if err != nil {
+		control.allowLinks.restore(token, link)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			fmt.Errorf("failed to allow ip %s for server %s: %s", clientIP, link.serviceName, err).Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, AllowResponse{clientIP, *expires})
+}
+
+func (control *Control) ResizeVolume(ctx *gin.Context) {
+	serverName, ok := ctx.Params.Get("name")
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+			errors.New("missing name parameter").Error(),
+		})
+		return
+	}
+	var req ResizeVolumeRequest
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+			fmt.Errorf("failed to bind request: %s", err).Error(),
+		})
+		return
+	}
+	req.ServerName = serverName
+
+	err = control.resizeVolume(ctx, req)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			fmt.Errorf("failed to resize volume of server %s: %s", serverName, err).Error(),
+		})
+		return
+	}
+
+	control.audit(ctx.GetString(ContextKeyUserID), "resize volume", serverName, fmt.Sprintf("%d GB", req.Size))
+
+	ctx.Status(http.StatusOK)
+}
+
+func (control *Control) GetCosts(ctx *gin.Context) {
+	month := time.Now()
+	if monthStr := ctx.Query("month"); monthStr != "" {
+		var err error
+		month, err = time.Parse(CostMonthLayout, monthStr)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+				fmt.Errorf("failed to parse month: %s", err).Error(),
+			})
+			return
+		}
+	}
+
+	report, err := control.costReport(ctx, month, control.viewFilter(ctx.MustGet(ContextKeyMember).(*discordgo.Member)))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			fmt.Errorf("failed to create cost report: %s", err).Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+func (control *Control) ListServerTypes(ctx *gin.Context) {
+	catalog, err := control.serverTypeCatalog(ctx, ctx.GetString(ContextKeyUserID))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			fmt.Errorf("failed to list server types: %s", err).Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, catalog)
+}
+
+func (control *Control) ListStartQueue(ctx *gin.Context) {
+	member := ctx.MustGet(ContextKeyMember).(*discordgo.Member)
+	ctx.JSON(http.StatusOK, slices.DeleteFunc(control.startQueue(), func(entry *QueuedStart) bool {
+		return !control.memberMayAccess(member, entry.ServerName, ActionView)
+	}))
+}
+
+func (control *Control) DequeueStart(ctx *gin.Context) {
+	serverName, ok := ctx.Params.Get("name")
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+			errors.New("missing name parameter").Error(),
+		})
+		return
+	}
+
+	userID := ctx.GetString(ContextKeyUserID)
+
+	member, err := control.guildMember(userID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			err.Error(),
+		})
+		return
+	}
+
+	err = control.dequeueStart(serverName, userID, member != nil && memberHasRole(member, control.Config.DiscordAdminRoleID))
+	if errors.Is(err, ErrNotQueued) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, APIError{
+			err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrUnauthorized) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			fmt.Errorf("failed to dequeue server %s: %s", serverName, err).Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (control *Control) TransferOwnership(ctx *gin.Context) {
+	serverName, ok := ctx.Params.Get("name")
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+			errors.New("missing name parameter").Error(),
+		})
+		return
+	}
+	var req TransferOwnershipRequest
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+			fmt.Errorf("failed to bind request: %s", err).Error(),
+		})
+		return
+	}
+	req.ServerName = serverName
+
+	control.audit(ctx.GetString(ContextKeyUserID), "transfer", serverName, req.Owner)
+	err = control.transferOwnership(ctx, req)
+	if errors.Is(err, ErrInvalidOwner) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+			err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			fmt.Errorf("failed to transfer ownership of server %s: %s", serverName, err).Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (control *Control) ListApprovalRequests(ctx *gin.Context) {
+	member := ctx.MustGet(ContextKeyMember).(*discordgo.Member)
+	ctx.JSON(http.StatusOK, slices.DeleteFunc(control.approvalRequests(ctx.Query("status")), func(request *ApprovalRequest) bool {
+		return !control.memberMayAccess(member, request.ServerName, ActionView)
+	}))
+}
+
+func (control *Control) ListSchedules(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, control.visibleSchedules(ctx.MustGet(ContextKeyMember).(*discordgo.Member)))
+}
+
+func (control *Control) CreateSchedule(ctx *gin.Context) {
+	var req CreateScheduleRequest
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+			fmt.Errorf("failed to bind request: %s", err).Error(),
+		})
+		return
+	}
+
+	err = control.checkAccess(ctx.MustGet(ContextKeyMember).(*discordgo.Member), req.ServerName, req.Action)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			err.Error(),
+		})
+		return
+	}
+
+	schedule, err := control.addSchedule(ctx, ctx.GetString(ContextKeyUserID), req)
+	if errors.Is(err, ErrNotOwner) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			fmt.Errorf("failed to add schedule: %s", err).Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+			fmt.Errorf("failed to add schedule: %s", err).Error(),
+		})
+		return
+	}
+
+	control.audit(ctx.GetString(ContextKeyUserID), "schedule", schedule.ServerName, fmt.Sprintf("%s at %s %s", schedule.Action, schedule.Cron, schedule.TimeZone))
+
+	ctx.JSON(http.StatusCreated, schedule)
+}
+
+func (control *Control) DeleteSchedule(ctx *gin.Context) {
+	id, ok := ctx.Params.Get("id")
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, APIError{
+			errors.New("missing id parameter").Error(),
+		})
+		return
+	}
+
+	err := control.checkScheduleAccess(ctx.MustGet(ContextKeyMember).(*discordgo.Member), id)
+	if errors.Is(err, ErrNoSchedule) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, APIError{
+			err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
+			err.Error(),
+		})
+		return
+	}
+
+	schedule, err := control.removeSchedule(id)
+	if errors.Is(err, ErrNoSchedule) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, APIError{
+			err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, APIError{
+			fmt.Errorf("failed to remove schedule %s: %s", id, err).Error(),
+		})
+		return
+	}
+
+	control.audit(ctx.GetString(ContextKeyUserID), "unschedule", schedule.ServerName, schedule.ID)
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,358 @@
+package control
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ApprovalStatusPending  = "pending"
+	ApprovalStatusApproved = "approved"
+	ApprovalStatusDenied   = "denied"
+	ApprovalStatusExpired  = "expired"
+
+	approveButtonPrefix = "approve:"
+	denyButtonPrefix    = "deny:"
+
+	approvalCleanupInterval = time.Minute
+	// decided requests are kept for a while, so they can still be looked up
+	approvalRetention = 24 * time.Hour
+)
+
+// ApprovalRequest is a start request of a regular user waiting for an admin or power user to approve it.
+type ApprovalRequest struct {
+	ID          string     `json:"id"`
+	ServerName  string     `json:"serverName"`
+	TTL         string     `json:"ttl"`
+	RequestedBy string     `json:"requestedBy"`
+	ChannelID   string     `json:"channelID"`
+	MessageID   string     `json:"messageID"`
+	Status      string     `json:"status"`
+	DecidedBy   string     `json:"decidedBy,omitempty"`
+	Created     time.Time  `json:"created"`
+	Expires     time.Time  `json:"expires"`
+	Decided     *time.Time `json:"decided,omitempty"`
+}
+
+// requestApproval posts the start request with approve and deny buttons, requests the requester could not start
+// because of the ttl, their quota or the budget are rejected right away.
+func (control *Control) requestApproval(s *discordgo.Session, m *discordgo.Message, req StartServerRequest) error {
+	ctx := context.Background()
+
+	ttlDuration, err := time.ParseDuration(req.TTL)
+	if err != nil {
+		return ErrIllegalArguments
+	}
+
+	err = control.checkStartQuota(ctx, m.Author.ID, ttlDuration)
+	if err != nil {
+		return err
+	}
+
+	startImage, serverType, err := control.serviceStartImage(ctx, req.ServerName)
+	if err != nil {
+		return err
+	}
+
+	location, err := control.serviceLocation(ctx, req.ServerName, startImage)
+	if err != nil {
+		return err
+	}
+
+	err = control.checkBudget(ctx, m.Author.ID, serverType, location.Name, ttlDuration)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 16)
+
+	_, err = rand.Read(buf)
+	if err != nil {
+		return fmt.Errorf("failed to create approval request for bot: %s", err)
+	}
+
+	now := time.Now()
+
+	request := &ApprovalRequest{
+		ID:          hex.EncodeToString(buf),
+		ServerName:  req.ServerName,
+		TTL:         req.TTL,
+		RequestedBy: m.Author.ID,
+		ChannelID:   m.ChannelID,
+		Status:      ApprovalStatusPending,
+		Created:     now,
+		Expires:     now.Add(control.Config.ApprovalTimeout),
+	}
+
+	pending := false
+
+	// the request is added together with the check, so concurrent requests of the same server can not both pass
+	err = control.state.update(func(state *persistentState) {
+		pending = slices.ContainsFunc(state.Approvals, func(r *ApprovalRequest) bool {
+			return r.ServerName == req.ServerName && r.Status == ApprovalStatusPending
+		})
+		if !pending {
+			state.Approvals = append(state.Approvals, request)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save approval request: %s", err)
+	}
+
+	if pending {
+		return fmt.Errorf("%w: a start of server %s has already been requested", ErrNotAllowed, req.ServerName)
+	}
+
+	msg, sendErr := s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+		Content: fmt.Sprintf(
+			"<@%s> requests to start server %s for %s. <@&%s> please approve or deny within %s.",
+			request.RequestedBy,
+			request.ServerName,
+			request.TTL,
+			control.Config.DiscordPowerUserRoleID,
+			control.Config.ApprovalTimeout,
+		),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{Label: "Approve", Style: discordgo.SuccessButton, CustomID: approveButtonPrefix + request.ID},
+					discordgo.Button{Label: "Deny", Style: discordgo.DangerButton, CustomID: denyButtonPrefix + request.ID},
+				},
+			},
+		},
+	})
+
+	// a request without its message can never be decided, so it is removed again
+	err = control.state.update(func(state *persistentState) {
+		if sendErr != nil {
+			state.Approvals = slices.DeleteFunc(state.Approvals, func(r *ApprovalRequest) bool {
+				return r.ID == request.ID
+			})
+			return
+		}
+
+		for _, r := range state.Approvals {
+			if r.ID == request.ID {
+				r.MessageID = msg.ID
+			}
+		}
+	})
+	if sendErr != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, sendErr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save approval request: %s", err)
+	}
+
+	log.Infof("approval: user %s requested to start server %s", request.RequestedBy, request.ServerName)
+
+	return nil
+}
+
+// approvalRequests returns the requests with the status or all requests if the status is empty.
+func (control *Control) approvalRequests(status string) []*ApprovalRequest {
+	requests := []*ApprovalRequest{}
+
+	control.state.view(func(state *persistentState) {
+		for _, request := range state.Approvals {
+			if status == "" || request.Status == status {
+				copied := *request
+				requests = append(requests, &copied)
+			}
+		}
+	})
+
+	return requests
+}
+
+// decideApproval sets the status of the pending request, it fails if the request is not pending anymore.
+func (control *Control) decideApproval(id, status, decidedBy string) (*ApprovalRequest, error) {
+	var decided *ApprovalRequest
+
+	now := time.Now()
+
+	err := control.state.update(func(state *persistentState) {
+		for _, request := range state.Approvals {
+			if request.ID != id || request.Status != ApprovalStatusPending || now.After(request.Expires) {
+				continue
+			}
+
+			request.Status = status
+			request.DecidedBy = decidedBy
+			request.Decided = new(now)
+
+			copied := *request
+			decided = &copied
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save approval request: %s", err)
+	}
+
+	if decided == nil {
+		return nil, errors.New("the request has expired or has already been decided")
+	}
+
+	return decided, nil
+}
+
+// handleApprovalInteraction handles clicks on the approve and deny buttons, only admins and power users
+// who may start the server can decide.
+func (control *Control) handleApprovalInteraction(s *discordgo.Session, i *discordgo.InteractionCreate, id string, approved bool) {
+	if i.Member == nil {
+		return
+	}
+
+	var request *ApprovalRequest
+
+	control.state.view(func(state *persistentState) {
+		for _, r := range state.Approvals {
+			if r.ID == id {
+				copied := *r
+				request = &copied
+			}
+		}
+	})
+
+	if request == nil ||
+		!memberHasRole(i.Member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID) ||
+		!control.memberMayAccess(i.Member, request.ServerName, ActionStart) {
+		respondEphemeral(s, i, "You are not allowed to decide this request.")
+		return
+	}
+
+	status := ApprovalStatusDenied
+	if approved {
+		status = ApprovalStatusApproved
+	}
+
+	request, err := control.decideApproval(id, status, i.Member.User.ID)
+	if err != nil {
+		respondEphemeral(s, i, fmt.Sprintf("I'm sorry, Dave. I'm afraid I can't do that: %s", err))
+		return
+	}
+
+	control.audit(i.Member.User.ID, status, request.ServerName, request.RequestedBy)
+
+	// the buttons are removed so the request can not be decided twice
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    fmt.Sprintf("%s\n%s by <@%s>.", i.Message.Content, strings.ToUpper(status[:1])+status[1:], i.Member.User.ID),
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		log.Errorf("discord: failed to respond to interaction of user %s: %s", i.Member.User.Username, err)
+	}
+
+	if !approved {
+		return
+	}
+
+	go control.runApprovedStart(context.Background(), s, request)
+}
+
+// runApprovedStart starts the server on behalf of the requester and announces the result in the request channel.
+func (control *Control) runApprovedStart(ctx context.Context, s *discordgo.Session, request *ApprovalRequest) {
+	server, err := control.startServer(ctx, request.RequestedBy, StartServerRequest{
+		ServerName: request.ServerName,
+		TTL:        request.TTL,
+		Confirm:    true,
+	})
+
+	announce := func(msg string) {
+		_, err := s.ChannelMessageSend(request.ChannelID, fmt.Sprintf("<@%s> %s", request.RequestedBy, msg))
+		if err != nil {
+			log.Errorf("discord: failed to announce approved start of server %s: %s", request.ServerName, err)
+		}
+	}
+
+	var queuedErr *QueuedError
+
+	switch {
+	case errors.As(err, &queuedErr):
+		announce(fmt.Sprintf("%s.", queuedErr))
+	case errors.Is(err, ErrBudgetExceeded) || errors.Is(err, ErrQuotaExceeded):
+		announce(fmt.Sprintf("Server %s could not be started: %s", request.ServerName, err))
+	case err != nil:
+		log.Errorf("approval error: failed to start server %s: %s", request.ServerName, err)
+		announce(fmt.Sprintf("Server %s could not be started.", request.ServerName))
+	default:
+		control.announceWhenReady(server, announce, fmt.Sprintf(
+			"Server %s is ready with DNS %s. It will run for %s",
+			server.Name,
+			serverDNSPtr(server),
+			request.TTL,
+		))
+	}
+}
+
+// expireApprovals expires pending requests after the approval timeout, removing their buttons,
+// and drops decided requests after the retention period.
+func (control *Control) expireApprovals() {
+	var expired []*ApprovalRequest
+
+	now := time.Now()
+
+	err := control.state.update(func(state *persistentState) {
+		state.Approvals = slices.DeleteFunc(state.Approvals, func(request *ApprovalRequest) bool {
+			return request.Decided != nil && now.Sub(*request.Decided) > approvalRetention
+		})
+
+		for _, request := range state.Approvals {
+			if request.Status != ApprovalStatusPending || now.Before(request.Expires) {
+				continue
+			}
+
+			request.Status = ApprovalStatusExpired
+			request.Decided = new(now)
+
+			copied := *request
+			expired = append(expired, &copied)
+		}
+	})
+	if err != nil {
+		log.Errorf("approval error: failed to save approval requests: %s", err)
+	}
+
+	for _, request := range expired {
+		log.Infof("approval: request to start server %s expired", request.ServerName)
+
+		_, err := control.discordSession.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:      request.MessageID,
+			Channel: request.ChannelID,
+			Content: new(fmt.Sprintf(
+				"The request of <@%s> to start server %s has expired.",
+				request.RequestedBy,
+				request.ServerName,
+			)),
+			Components: &[]discordgo.MessageComponent{},
+		})
+		if err != nil {
+			log.Errorf("discord: failed to expire approval request of server %s: %s", request.ServerName, err)
+		}
+	}
+}
+
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Errorf("discord: failed to respond to interaction: %s", err)
+	}
+}
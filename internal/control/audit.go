@@ -0,0 +1,33 @@
+package control
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// audit records an action taken by a user in the log and, if configured, in the discord audit channel.
+func (control *Control) audit(actor, action, target, detail string) {
+	log.WithFields(log.Fields{
+		"audit":  true,
+		"actor":  actor,
+		"action": action,
+		"target": target,
+		"detail": detail,
+	}).Info("audit")
+
+	if control.Config.DiscordAuditChannelID == "" {
+		return
+	}
+
+	_, err := control.discordSession.ChannelMessageSend(control.Config.DiscordAuditChannelID, fmt.Sprintf(
+		"<@%s> %s %s: `%s`",
+		actor,
+		action,
+		target,
+		detail,
+	))
+	if err != nil {
+		log.Errorf("discord: failed to send audit message: %s", err)
+	}
+}
@@ -15,6 +15,11 @@ import (
 	"github.com/markbates/goth/providers/discord"
 )
 
+const (
+	ContextKeyUserID = "userID"
+	ContextKeyMember = "member"
+)
+
 func AuthSetup(callbackURL string) {
 	goth.UseProviders(
 		discord.New(
@@ -145,13 +150,17 @@ func (control *Control) Authorize() gin.HandlerFunc {
 			return
 		}
 
-		if !memberHasRole(member, control.Config.DiscordAdminRoleID) {
+		// the routes check the roles required for their action, just like the bot commands
+		if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID, control.Config.DiscordUserRoleID) {
 			ctx.AbortWithStatusJSON(http.StatusForbidden, APIError{
 				fmt.Errorf("forbidden: permission check failed: %s", err).Error(),
 			})
 			return
 		}
 
+		ctx.Set(ContextKeyUserID, member.User.ID)
+		ctx.Set(ContextKeyMember, member)
+
 		ctx.Next()
 	}
 }
@@ -0,0 +1,335 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	budgetKeyGlobal = "global"
+	// the hard stop is checked more often than the servers are reconciled
+	budgetCheckInterval   = time.Minute
+	budgetWarningInterval = 5 * time.Minute
+)
+
+var (
+	budgetWarningThresholds = []int{50, 80, 100}
+
+	ErrBudgetExceeded = errors.New("budget exceeded")
+)
+
+// BudgetConfig limits the monthly costs, role budgets apply to every member of the role
+// and user budgets take precedence over them.
+type BudgetConfig struct {
+	Global   float64            `json:"global,omitempty"`
+	Roles    map[string]float64 `json:"roles,omitempty"`
+	Users    map[string]float64 `json:"users,omitempty"`
+	HardStop bool               `json:"hardStop,omitempty"`
+}
+
+// LoadBudgetConfig reads the budgets file.
+func LoadBudgetConfig(path string) (*BudgetConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read budgets file: %s", err)
+	}
+
+	budget := &BudgetConfig{}
+
+	err = json.Unmarshal(data, budget)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse budgets file: %s", err)
+	}
+
+	return budget, nil
+}
+
+// checkBudget refuses to run the server type in the location for the duration if it would exceed the global budget
+// or the budget of the actor together with the remaining runtime of the running servers, admins are allowed to exceed budgets.
+func (control *Control) checkBudget(ctx context.Context, actor, serverType, location string, duration time.Duration) error {
+	budget := control.Config.Budget
+	if budget == nil {
+		return nil
+	}
+
+	member, err := control.guildMember(actor)
+	if err != nil {
+		return err
+	}
+
+	if member != nil && memberHasRole(member, control.Config.DiscordAdminRoleID) {
+		return nil
+	}
+
+	projected, _, err := control.projectedCost(ctx, serverType, location, duration)
+	if err != nil {
+		return err
+	}
+
+	report, err := control.currentCostReport(ctx)
+	if err != nil {
+		return err
+	}
+
+	running, runningUsers, err := control.runningCost(ctx)
+	if err != nil {
+		return err
+	}
+
+	if budget.Global > 0 && report.Total+running+projected > budget.Global {
+		return fmt.Errorf(
+			"%w: the global budget of %.2f %s would be exceeded (%.2f spent, %.2f projected), ask an admin",
+			ErrBudgetExceeded, budget.Global, report.Currency, report.Total, running+projected,
+		)
+	}
+
+	if member == nil {
+		return nil
+	}
+
+	limit, ok := control.memberBudget(member)
+	if ok && report.Users[actor]+runningUsers[actor]+projected > limit {
+		return fmt.Errorf(
+			"%w: your budget of %.2f %s would be exceeded (%.2f spent, %.2f projected), ask an admin",
+			ErrBudgetExceeded, limit, report.Currency, report.Users[actor], runningUsers[actor]+projected,
+		)
+	}
+
+	return nil
+}
+
+// runningCost projects the cost of the running servers until their ttl, in total and per user who started them.
+func (control *Control) runningCost(ctx context.Context) (float64, map[string]float64, error) {
+	servers, err := control.listServers(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	pricing, err := control.pricing(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var total float64
+	users := make(map[string]float64)
+
+	now := time.Now()
+
+	for _, server := range servers {
+		ttl, err := serverTTL(server)
+		if err != nil || !ttl.After(now) {
+			continue
+		}
+
+		hourlyPrice, _, err := serverTypePrices(pricing, server.ServerType.Name, control.serverLocationName(server))
+		if err != nil {
+			log.Errorf("budget error: %s", err)
+			continue
+		}
+
+		cost := math.Ceil(ttl.Sub(now).Hours()) * hourlyPrice
+
+		total += cost
+		if startedBy := server.Labels[LabelStartedBy]; startedBy != "" {
+			users[startedBy] += cost
+		}
+	}
+
+	return total, users, nil
+}
+
+// memberBudget returns the budget of the member, the highest role budget applies if the user has none.
+func (control *Control) memberBudget(member *discordgo.Member) (float64, bool) {
+	budget := control.Config.Budget
+
+	if limit, ok := budget.Users[member.User.ID]; ok {
+		return limit, true
+	}
+
+	var limit float64
+	var found bool
+
+	for _, roleID := range member.Roles {
+		if roleLimit, ok := budget.Roles[roleID]; ok && (!found || roleLimit > limit) {
+			limit, found = roleLimit, true
+		}
+	}
+
+	return limit, found
+}
+
+// checkBudgetWarnings posts a warning once per month and threshold.
+func (control *Control) checkBudgetWarnings(ctx context.Context) {
+	budget := control.Config.Budget
+	if budget == nil {
+		return
+	}
+
+	report, err := control.currentCostReport(ctx)
+	if err != nil {
+		log.Errorf("budget error: %s", err)
+		return
+	}
+
+	if budget.Global > 0 {
+		percent := report.Total / budget.Global * 100
+
+		control.warnBudget(report.Month, budgetKeyGlobal, percent, fmt.Sprintf(
+			"The global budget is %.0f%% used up (%.2f of %.2f %s).",
+			percent, report.Total, budget.Global, report.Currency,
+		))
+	}
+
+	if len(budget.Roles) == 0 && len(budget.Users) == 0 {
+		return
+	}
+
+	for userID, spent := range report.Users {
+		member, err := control.discordSession.GuildMember(control.Config.DiscordGuildID, userID)
+		if err != nil {
+			log.Errorf("budget error: failed to get guild member %s: %s", userID, err)
+			continue
+		}
+
+		limit, ok := control.memberBudget(member)
+		if !ok || limit <= 0 {
+			continue
+		}
+
+		percent := spent / limit * 100
+
+		control.warnBudget(report.Month, userID, percent, fmt.Sprintf(
+			"<@%s> your budget is %.0f%% used up (%.2f of %.2f %s).",
+			userID, percent, spent, limit, report.Currency,
+		))
+	}
+}
+
+// warnBudget posts the message if a threshold was crossed which has not been warned about this month.
+func (control *Control) warnBudget(month, key string, percent float64, msg string) {
+	var threshold int
+
+	for _, t := range budgetWarningThresholds {
+		if percent >= float64(t) {
+			threshold = t
+		}
+	}
+
+	if threshold == 0 {
+		return
+	}
+
+	warned := false
+
+	err := control.state.update(func(state *persistentState) {
+		if state.BudgetWarnings == nil {
+			state.BudgetWarnings = make(map[string]int)
+		}
+
+		stateKey := month + "/" + key
+		if state.BudgetWarnings[stateKey] >= threshold {
+			warned = true
+			return
+		}
+
+		state.BudgetWarnings[stateKey] = threshold
+	})
+	if err != nil {
+		log.Errorf("budget error: failed to save budget warning: %s", err)
+	}
+
+	if !warned {
+		control.notify(msg)
+	}
+}
+
+// checkHardStop stops all servers not started by admins if the global budget is used up and hard stop is enabled.
+func (control *Control) checkHardStop(ctx context.Context) {
+	budget := control.Config.Budget
+	if budget == nil || !budget.HardStop || budget.Global <= 0 {
+		return
+	}
+
+	report, err := control.currentCostReport(ctx)
+	if err != nil {
+		log.Errorf("budget error: %s", err)
+		return
+	}
+
+	if report.Total < budget.Global {
+		return
+	}
+
+	servers, err := control.listServers(ctx)
+	if err != nil {
+		log.Errorf("budget error: %s", err)
+		return
+	}
+
+	control.hardStop(ctx, servers)
+}
+
+// hardStop expires all servers which have not been expired yet, except the servers started by admins
+// as admins are allowed to exceed budgets.
+func (control *Control) hardStop(ctx context.Context, servers []*hcloud.Server) {
+	var running []*hcloud.Server
+
+	for _, server := range servers {
+		ttl, err := serverTTL(server)
+		if err == nil && !ttl.After(time.Now()) {
+			continue
+		}
+
+		if control.startedByAdmin(server) {
+			continue
+		}
+
+		running = append(running, server)
+	}
+
+	if len(running) == 0 {
+		return
+	}
+
+	control.alert(fmt.Sprintf("The global budget is used up, stopping %d servers.", len(running)))
+
+	for _, server := range running {
+		control.expireServer(ctx, server)
+	}
+}
+
+// startedByAdmin reports whether the server was started by an admin, servers of unknown members are not.
+func (control *Control) startedByAdmin(server *hcloud.Server) bool {
+	member, err := control.guildMember(server.Labels[LabelStartedBy])
+	if err != nil {
+		log.Errorf("budget error: %s", err)
+		return false
+	}
+
+	return member != nil && memberHasRole(member, control.Config.DiscordAdminRoleID)
+}
+
+// expireServer sets the ttl of the server to now, so the scheduler terminates it right away.
+func (control *Control) expireServer(ctx context.Context, server *hcloud.Server) {
+	now := time.Now()
+
+	server.Labels[LabelTTL] = strconv.Itoa(int(now.Unix()))
+
+	_, _, err := control.hclient.Server.Update(ctx, server, hcloud.ServerUpdateOpts{Labels: server.Labels})
+	if err != nil {
+		log.Errorf("budget error: failed to update ttl of server %s: %s", server.Name, err)
+		return
+	}
+
+	control.scheduler.schedule(server.Name, now)
+}
@@ -0,0 +1,157 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestMemberBudget(t *testing.T) {
+	control := &Control{Config: &Config{Budget: &BudgetConfig{
+		Roles: map[string]float64{testUserRoleID: 5, testPowerRoleID: 20},
+		Users: map[string]float64{"vip": 2},
+	}}}
+
+	tests := []struct {
+		name     string
+		userID   string
+		roles    []string
+		expected float64
+		found    bool
+	}{
+		{name: "no budget", userID: "someone", roles: []string{"other"}},
+		{name: "role", userID: "someone", roles: []string{testUserRoleID}, expected: 5, found: true},
+		{name: "highest role", userID: "someone", roles: []string{testUserRoleID, testPowerRoleID}, expected: 20, found: true},
+		{name: "user", userID: "vip", roles: []string{testPowerRoleID}, expected: 2, found: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, found := control.memberBudget(&discordgo.Member{User: &discordgo.User{ID: tt.userID}, Roles: tt.roles})
+			if limit != tt.expected || found != tt.found {
+				t.Errorf("expected %.2f %t, got %.2f %t", tt.expected, tt.found, limit, found)
+			}
+		})
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	// the running server costs 3 started hours and the requested 2 hours, 0.01 each
+	ttl := strconv.Itoa(int(time.Now().Add(150 * time.Minute).Unix()))
+
+	tests := []struct {
+		name      string
+		budget    *BudgetConfig
+		roles     []string
+		startedBy string
+		err       error
+	}{
+		{name: "within", budget: &BudgetConfig{Global: 0.06, Roles: map[string]float64{testUserRoleID: 0.06}}, roles: []string{testUserRoleID}, startedBy: "actor"},
+		{name: "global budget", budget: &BudgetConfig{Global: 0.04}, roles: []string{testUserRoleID}, startedBy: "other", err: ErrBudgetExceeded},
+		{name: "user budget", budget: &BudgetConfig{Roles: map[string]float64{testUserRoleID: 0.04}}, roles: []string{testUserRoleID}, startedBy: "actor", err: ErrBudgetExceeded},
+		{name: "servers of others", budget: &BudgetConfig{Roles: map[string]float64{testUserRoleID: 0.04}}, roles: []string{testUserRoleID}, startedBy: "other"},
+		{name: "admin", budget: &BudgetConfig{Global: 0.01}, roles: []string{testAdminRoleID}, startedBy: "actor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			control := newTestControl(t, &Config{Budget: tt.budget}, map[string]any{
+				"GET /pricing":            testPricingAnswer,
+				"GET /images":             map[string]any{"images": []any{}},
+				"GET /volumes":            map[string]any{"volumes": []any{}},
+				"GET /servers":            testServers(testServer(1, "minecraft", map[string]string{LabelTTL: ttl, LabelStartedBy: tt.startedBy})),
+				testMemberRoute + "actor": testMember("actor", tt.roles...),
+			})
+
+			err := control.checkBudget(context.Background(), "actor", "cx22", testLocation, 2*time.Hour)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestCostCache(t *testing.T) {
+	var mutex sync.Mutex
+	requests := 0
+
+	control := newTestControl(t, &Config{}, map[string]any{
+		"GET /pricing": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			requests++
+			mutex.Unlock()
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(testPricingAnswer))
+		}),
+		"GET /images":  map[string]any{"images": []any{}},
+		"GET /volumes": map[string]any{"volumes": []any{}},
+	})
+
+	for range 3 {
+		_, err := control.currentCostReport(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if requests != 1 {
+		t.Errorf("expected 1 pricing request before the reset, got %d", requests)
+	}
+
+	control.costCache.reset()
+
+	_, err := control.currentCostReport(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if requests != 2 {
+		t.Errorf("expected 2 pricing requests after the reset, got %d", requests)
+	}
+}
+
+func TestHardStop(t *testing.T) {
+	var mutex sync.Mutex
+	var expired []string
+
+	control := newTestControl(t, &Config{}, map[string]any{
+		testMemberRoute + "admin": testMember("admin", testAdminRoleID),
+		testMemberRoute + "user":  testMember("user", testUserRoleID),
+		"PUT /servers/{id}": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			expired = append(expired, r.PathValue("id"))
+			mutex.Unlock()
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"server": {"id": 1, "name": "server"}}`))
+		}),
+	})
+
+	future := strconv.Itoa(int(time.Now().Add(time.Hour).Unix()))
+	past := strconv.Itoa(int(time.Now().Add(-time.Hour).Unix()))
+
+	servers := []*hcloud.Server{
+		{ID: 1, Name: "minecraft", Labels: map[string]string{LabelTTL: future, LabelStartedBy: "user"}},
+		{ID: 2, Name: "valheim", Labels: map[string]string{LabelTTL: future, LabelStartedBy: "admin"}},
+		{ID: 3, Name: "factorio", Labels: map[string]string{LabelTTL: past, LabelStartedBy: "user"}},
+		{ID: 4, Name: "satisfactory", Labels: map[string]string{LabelTTL: future}},
+	}
+
+	control.hardStop(context.Background(), servers)
+
+	slices.Sort(expired)
+
+	// servers of admins and servers already past their ttl are left alone
+	if expected := []string{"1", "4"}; !slices.Equal(expired, expected) {
+		t.Errorf("expected %q to be expired, got %q", expected, expired)
+	}
+}
@@ -0,0 +1,185 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	cloudflareAPIURL  = "https://api.cloudflare.com/client/v4"
+	cloudflareTimeout = 10 * time.Second
+)
+
+// cloudflareDNSProvider manages records through the cloudflare api, which knows single records instead of record sets.
+type cloudflareDNSProvider struct {
+	apiURL string
+	token  string
+	zoneID string
+	zone   string
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content,omitempty"`
+	Data    any    `json:"data,omitempty"`
+	TTL     int    `json:"ttl"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type cloudflareSRVData struct {
+	Priority int    `json:"priority"`
+	Weight   int    `json:"weight"`
+	Port     int    `json:"port"`
+	Target   string `json:"target"`
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+func (provider *cloudflareDNSProvider) SetRecord(ctx context.Context, record DNSRecord) error {
+	err := provider.DeleteRecord(ctx, record.Name, record.Type)
+	if err != nil {
+		return err
+	}
+
+	for _, value := range record.Values {
+		cfRecord := cloudflareRecord{
+			Type:    record.Type,
+			Name:    provider.fqdn(record.Name),
+			Content: strings.TrimSuffix(value, "."),
+			TTL:     record.TTL,
+			Comment: dnsRecordComment,
+		}
+
+		// srv records are not accepted as content but have to be passed as structured data
+		if record.Type == "SRV" {
+			cfRecord.Data, err = parseSRVValue(value)
+			if err != nil {
+				return err
+			}
+			cfRecord.Content = ""
+		}
+
+		err = provider.request(ctx, http.MethodPost, "/dns_records", cfRecord, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create record %s %s: %s", record.Name, record.Type, err)
+		}
+	}
+
+	return nil
+}
+
+func (provider *cloudflareDNSProvider) DeleteRecord(ctx context.Context, name, recordType string) error {
+	query := url.Values{}
+	query.Set("type", recordType)
+	query.Set("name", provider.fqdn(name))
+
+	var records []cloudflareRecord
+
+	err := provider.request(ctx, http.MethodGet, "/dns_records?"+query.Encode(), nil, &records)
+	if err != nil {
+		return fmt.Errorf("failed to list records %s %s: %s", name, recordType, err)
+	}
+
+	for _, record := range records {
+		err = provider.request(ctx, http.MethodDelete, "/dns_records/"+record.ID, nil, nil)
+		if err != nil {
+			return fmt.Errorf("failed to delete record %s %s: %s", name, recordType, err)
+		}
+	}
+
+	return nil
+}
+
+func (provider *cloudflareDNSProvider) request(ctx context.Context, method, path string, body, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, cloudflareTimeout)
+	defer cancel()
+
+	var reqBody io.Reader
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, provider.apiURL+"/zones/"+provider.zoneID+path, reqBody)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+provider.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var cfResp cloudflareResponse
+
+	err = json.NewDecoder(resp.Body).Decode(&cfResp)
+	if err != nil {
+		return fmt.Errorf("failed to decode response with status code %d: %s", resp.StatusCode, err)
+	}
+
+	if !cfResp.Success {
+		if len(cfResp.Errors) > 0 {
+			return fmt.Errorf("cloudflare error %d: %s", cfResp.Errors[0].Code, cfResp.Errors[0].Message)
+		}
+		return fmt.Errorf("cloudflare request failed with status code %d", resp.StatusCode)
+	}
+
+	if result != nil {
+		return json.Unmarshal(cfResp.Result, result)
+	}
+
+	return nil
+}
+
+func parseSRVValue(value string) (*cloudflareSRVData, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("invalid srv value %s", value)
+	}
+
+	var numbers [3]int
+
+	for i := range numbers {
+		number, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid srv value %s: %s", value, err)
+		}
+		numbers[i] = number
+	}
+
+	return &cloudflareSRVData{
+		Priority: numbers[0],
+		Weight:   numbers[1],
+		Port:     numbers[2],
+		Target:   strings.TrimSuffix(fields[3], "."),
+	}, nil
+}
+
+func (provider *cloudflareDNSProvider) fqdn(name string) string {
+	return name + "." + provider.zone
+}
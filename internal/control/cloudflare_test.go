@@ -0,0 +1,219 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testCloudflare is an in-process cloudflare api which keeps the records of one zone.
+type testCloudflare struct {
+	mutex   sync.Mutex
+	nextID  int
+	records []cloudflareRecord
+}
+
+func startTestCloudflare(t *testing.T, records ...cloudflareRecord) (*testCloudflare, *cloudflareDNSProvider) {
+	t.Helper()
+
+	cf := &testCloudflare{records: records, nextID: len(records) + 1}
+
+	answer := func(w http.ResponseWriter, result any) {
+		data, _ := json.Marshal(result)
+		_ = json.NewEncoder(w).Encode(cloudflareResponse{Success: true, Result: data})
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /zones/zone/dns_records", func(w http.ResponseWriter, r *http.Request) {
+		cf.mutex.Lock()
+		defer cf.mutex.Unlock()
+
+		matching := []cloudflareRecord{}
+		for _, record := range cf.records {
+			if record.Type == r.URL.Query().Get("type") && record.Name == r.URL.Query().Get("name") {
+				matching = append(matching, record)
+			}
+		}
+
+		answer(w, matching)
+	})
+
+	mux.HandleFunc("POST /zones/zone/dns_records", func(w http.ResponseWriter, r *http.Request) {
+		cf.mutex.Lock()
+		defer cf.mutex.Unlock()
+
+		var record cloudflareRecord
+
+		err := json.NewDecoder(r.Body).Decode(&record)
+		if err != nil || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"success": false, "errors": [{"code": 9207, "message": "invalid request"}]}`))
+			return
+		}
+
+		record.ID = fmt.Sprint(cf.nextID)
+		cf.nextID++
+		cf.records = append(cf.records, record)
+
+		answer(w, record)
+	})
+
+	mux.HandleFunc("DELETE /zones/zone/dns_records/{id}", func(w http.ResponseWriter, r *http.Request) {
+		cf.mutex.Lock()
+		defer cf.mutex.Unlock()
+
+		cf.records = slices.DeleteFunc(cf.records, func(record cloudflareRecord) bool {
+			return record.ID == r.PathValue("id")
+		})
+
+		answer(w, map[string]string{"id": r.PathValue("id")})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return cf, &cloudflareDNSProvider{apiURL: server.URL, token: "token", zoneID: "zone", zone: "example.com"}
+}
+
+// recordStrings returns the records as sorted "name type ttl content" lines, srv records show their data.
+func (cf *testCloudflare) recordStrings() []string {
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	var lines []string
+
+	for _, record := range cf.records {
+		content := record.Content
+		if record.Data != nil {
+			data, _ := json.Marshal(record.Data)
+			content = string(data)
+		}
+
+		lines = append(lines, fmt.Sprintf("%s %s %d %s", record.Name, record.Type, record.TTL, content))
+	}
+
+	slices.Sort(lines)
+
+	return lines
+}
+
+func TestCloudflareSetRecord(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []cloudflareRecord
+		record   DNSRecord
+		expected []string
+	}{
+		{
+			name:     "create",
+			record:   DNSRecord{Name: "minecraft", Type: "A", TTL: 60, Values: []string{"192.0.2.1"}},
+			expected: []string{"minecraft.example.com A 60 192.0.2.1"},
+		},
+		{
+			// the values of a previous server are replaced, other records are kept
+			name: "replace",
+			existing: []cloudflareRecord{
+				{ID: "1", Name: "minecraft.example.com", Type: "A", TTL: 60, Content: "192.0.2.1"},
+				{ID: "2", Name: "minecraft.example.com", Type: "AAAA", TTL: 60, Content: "2001:db8::1"},
+				{ID: "3", Name: "factorio.example.com", Type: "A", TTL: 60, Content: "192.0.2.3"},
+			},
+			record: DNSRecord{Name: "minecraft", Type: "A", TTL: 300, Values: []string{"192.0.2.2"}},
+			expected: []string{
+				"factorio.example.com A 60 192.0.2.3",
+				"minecraft.example.com A 300 192.0.2.2",
+				"minecraft.example.com AAAA 60 2001:db8::1",
+			},
+		},
+		{
+			name:     "cname",
+			record:   DNSRecord{Name: "mc", Type: "CNAME", TTL: 60, Values: []string{"minecraft.example.com."}},
+			expected: []string{"mc.example.com CNAME 60 minecraft.example.com"},
+		},
+		{
+			name:     "srv",
+			record:   DNSRecord{Name: "_minecraft._tcp.minecraft", Type: "SRV", TTL: 60, Values: []string{"0 5 25565 minecraft.example.com."}},
+			expected: []string{`_minecraft._tcp.minecraft.example.com SRV 60 {"port":25565,"priority":0,"target":"minecraft.example.com","weight":5}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cf, provider := startTestCloudflare(t, tt.existing...)
+
+			err := provider.SetRecord(context.Background(), tt.record)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if records := cf.recordStrings(); !slices.Equal(records, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, records)
+			}
+		})
+	}
+}
+
+func TestCloudflareDeleteRecord(t *testing.T) {
+	cf, provider := startTestCloudflare(t,
+		cloudflareRecord{ID: "1", Name: "minecraft.example.com", Type: "A", TTL: 60, Content: "192.0.2.1"},
+		cloudflareRecord{ID: "2", Name: "minecraft.example.com", Type: "A", TTL: 60, Content: "192.0.2.2"},
+		cloudflareRecord{ID: "3", Name: "minecraft.example.com", Type: "AAAA", TTL: 60, Content: "2001:db8::1"},
+	)
+
+	err := provider.DeleteRecord(context.Background(), "minecraft", "A")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{"minecraft.example.com AAAA 60 2001:db8::1"}
+	if records := cf.recordStrings(); !slices.Equal(records, expected) {
+		t.Errorf("expected %q, got %q", expected, records)
+	}
+}
+
+func TestCloudflareError(t *testing.T) {
+	_, provider := startTestCloudflare(t)
+	provider.token = "wrong"
+
+	err := provider.SetRecord(context.Background(), DNSRecord{Name: "minecraft", Type: "A", TTL: 60, Values: []string{"192.0.2.1"}})
+	if err == nil || !strings.Contains(err.Error(), "cloudflare error 9207: invalid request") {
+		t.Fatalf("expected the cloudflare error, got %v", err)
+	}
+}
+
+func TestParseSRVValue(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected *cloudflareSRVData
+	}{
+		{value: "0 5 25565 minecraft.example.com.", expected: &cloudflareSRVData{Priority: 0, Weight: 5, Port: 25565, Target: "minecraft.example.com"}},
+		{value: "10 0 2456 valheim.example.com", expected: &cloudflareSRVData{Priority: 10, Weight: 0, Port: 2456, Target: "valheim.example.com"}},
+		{value: "0 5 minecraft.example.com."},
+		{value: "0 5 port minecraft.example.com."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			data, err := parseSRVValue(tt.value)
+			if tt.expected == nil {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if *data != *tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, data)
+			}
+		})
+	}
+}
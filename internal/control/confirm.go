@@ -0,0 +1,269 @@
+package control
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	confirmationTTL = 5 * time.Minute
+
+	confirmButtonPrefix = "confirm:"
+	cancelButtonPrefix  = "cancel:"
+)
+
+// CostConfirmationError is returned when the projected cost of a server exceeds the confirmation threshold.
+type CostConfirmationError struct {
+	ServerName    string  `json:"serverName"`
+	ServerType    string  `json:"serverType"`
+	TTL           string  `json:"ttl"`
+	ProjectedCost float64 `json:"projectedCost"`
+	Currency      string  `json:"currency"`
+}
+
+func (err *CostConfirmationError) Error() string {
+	return fmt.Sprintf(
+		"the projected cost of %.2f %s for running server %s as %s for %s requires confirmation",
+		err.ProjectedCost, err.Currency, err.ServerName, err.ServerType, err.TTL,
+	)
+}
+
+type pendingConfirmation struct {
+	member  *discordgo.Member
+	message *discordgo.Message
+	expires time.Time
+}
+
+// confirmations holds the bot commands waiting for a click on their confirm button.
+type confirmations struct {
+	mutex     sync.Mutex
+	pending   map[string]pendingConfirmation
+	confirmed map[string]bool
+}
+
+func newConfirmations() *confirmations {
+	return &confirmations{
+		pending:   make(map[string]pendingConfirmation),
+		confirmed: make(map[string]bool),
+	}
+}
+
+func (c *confirmations) add(member *discordgo.Member, m *discordgo.Message) (string, error) {
+	buf := make([]byte, 16)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	id := hex.EncodeToString(buf)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+
+	for pendingID, pending := range c.pending {
+		if now.After(pending.expires) {
+			delete(c.pending, pendingID)
+		}
+	}
+
+	c.pending[id] = pendingConfirmation{member: member, message: m, expires: now.Add(confirmationTTL)}
+
+	return id, nil
+}
+
+// take removes the pending confirmation, it must have been requested by the given user.
+func (c *confirmations) take(id, userID string) (pendingConfirmation, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	pending, ok := c.pending[id]
+	if !ok || pending.member.User.ID != userID || time.Now().After(pending.expires) {
+		return pendingConfirmation{}, false
+	}
+
+	delete(c.pending, id)
+
+	return pending, true
+}
+
+func (c *confirmations) confirm(messageID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.confirmed[messageID] = true
+}
+
+// isConfirmed reports whether the message has been confirmed and forgets the confirmation.
+func (c *confirmations) isConfirmed(messageID string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	confirmed := c.confirmed[messageID]
+	delete(c.confirmed, messageID)
+
+	return confirmed
+}
+
+// projectedCost returns the cost of running the server type in the location for the duration, billed per started hour.
+func (control *Control) projectedCost(ctx context.Context, serverType, location string, duration time.Duration) (float64, string, error) {
+	pricing, err := control.pricing(ctx)
+	if err != nil {
+		return 0, "", err
+	}
+
+	hourlyPrice, _, err := serverTypePrices(pricing, serverType, location)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return math.Ceil(duration.Hours()) * hourlyPrice, pricing.Currency, nil
+}
+
+// checkCostConfirmation requires a confirmation if the projected cost exceeds the configured threshold.
+func (control *Control) checkCostConfirmation(ctx context.Context, serverName, serverType, location, ttl string, duration time.Duration, confirmed bool) error {
+	if confirmed || control.Config.ConfirmCostAbove <= 0 {
+		return nil
+	}
+
+	cost, currency, err := control.projectedCost(ctx, serverType, location, duration)
+	if err != nil {
+		return err
+	}
+
+	if cost <= control.Config.ConfirmCostAbove {
+		return nil
+	}
+
+	return &CostConfirmationError{
+		ServerName:    serverName,
+		ServerType:    serverType,
+		TTL:           ttl,
+		ProjectedCost: cost,
+		Currency:      currency,
+	}
+}
+
+// requestConfirmation asks the author of the message to confirm the costs, the command is run again once confirmed.
+func (control *Control) requestConfirmation(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message, confirmErr *CostConfirmationError) error {
+	id, err := control.confirmations.add(member, m)
+	if err != nil {
+		return fmt.Errorf("failed to create confirmation for bot: %s", err)
+	}
+	_, err = s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+		Content: fmt.Sprintf(
+			"<@%s> running server %s as %s for %s will cost about %.2f %s. Do you want to continue?",
+			m.Author.ID,
+			confirmErr.ServerName,
+			confirmErr.ServerType,
+			confirmErr.TTL,
+			confirmErr.ProjectedCost,
+			confirmErr.Currency,
+		),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{Label: "Confirm", Style: discordgo.SuccessButton, CustomID: confirmButtonPrefix + id},
+					discordgo.Button{Label: "Cancel", Style: discordgo.SecondaryButton, CustomID: cancelButtonPrefix + id},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+	}
+	return nil
+}
+
+// handleDiscordInteraction handles slash commands and clicks on the confirm, approval and vote buttons.
+func (control *Control) handleDiscordInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		control.handleSlashCommand(s, i)
+		return
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		control.handleSlashCommandAutocomplete(s, i)
+		return
+	case discordgo.InteractionMessageComponent:
+	default:
+		return
+	}
+
+	customID := i.MessageComponentData().CustomID
+
+	var id string
+	var confirmed bool
+
+	switch {
+	case strings.HasPrefix(customID, confirmButtonPrefix):
+		id, confirmed = strings.TrimPrefix(customID, confirmButtonPrefix), true
+	case strings.HasPrefix(customID, cancelButtonPrefix):
+		id = strings.TrimPrefix(customID, cancelButtonPrefix)
+	case strings.HasPrefix(customID, approveButtonPrefix):
+		control.handleApprovalInteraction(s, i, strings.TrimPrefix(customID, approveButtonPrefix), true)
+		return
+	case strings.HasPrefix(customID, denyButtonPrefix):
+		control.handleApprovalInteraction(s, i, strings.TrimPrefix(customID, denyButtonPrefix), false)
+		return
+	case strings.HasPrefix(customID, voteButtonPrefix):
+		control.handleVoteInteraction(s, i, strings.TrimPrefix(customID, voteButtonPrefix))
+		return
+	default:
+		return
+	}
+
+	user := i.User
+	if i.Member != nil {
+		user = i.Member.User
+	}
+
+	pending, ok := control.confirmations.take(id, user.ID)
+	if !ok {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "This confirmation has expired or is not yours.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		if err != nil {
+			log.Errorf("discord: failed to respond to interaction of user %s: %s", user.Username, err)
+		}
+		return
+	}
+
+	content := "Cancelled."
+	if confirmed {
+		content = "Confirmed."
+	}
+
+	// the buttons are removed so the confirmation can not be clicked twice
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    i.Message.Content + "\n" + content,
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		log.Errorf("discord: failed to respond to interaction of user %s: %s", user.Username, err)
+	}
+
+	if !confirmed {
+		return
+	}
+
+	control.confirmations.confirm(pending.message.ID)
+	control.handleDiscordCommand(pending.member, s, pending.message)
+}
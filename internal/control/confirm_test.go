@@ -0,0 +1,52 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckCostConfirmation(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold float64
+		duration  time.Duration
+		confirmed bool
+		// projectedCost is only checked if a confirmation is required
+		projectedCost float64
+	}{
+		{name: "disabled", duration: 24 * time.Hour},
+		{name: "below", threshold: 0.05, duration: 4 * time.Hour},
+		// 90 minutes are billed as 2 started hours
+		{name: "started hours", threshold: 0.015, duration: 90 * time.Minute, projectedCost: 0.02},
+		{name: "above", threshold: 0.05, duration: 6 * time.Hour, projectedCost: 0.06},
+		{name: "confirmed", threshold: 0.05, duration: 6 * time.Hour, confirmed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			control := newTestControl(t, &Config{ConfirmCostAbove: tt.threshold}, map[string]any{"GET /pricing": testPricingAnswer})
+
+			err := control.checkCostConfirmation(context.Background(), "minecraft", "cx22", testLocation, "6h", tt.duration, tt.confirmed)
+
+			var confirmErr *CostConfirmationError
+			if !errors.As(err, &confirmErr) {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if tt.projectedCost != 0 {
+					t.Fatalf("expected a confirmation of %.2f, got none", tt.projectedCost)
+				}
+				return
+			}
+
+			if tt.projectedCost == 0 {
+				t.Fatalf("expected no confirmation, got %s", err)
+			}
+			if !costEqual(confirmErr.ProjectedCost, tt.projectedCost) || confirmErr.Currency != "EUR" {
+				t.Errorf("expected %.2f EUR, got %.2f %s", tt.projectedCost, confirmErr.ProjectedCost, confirmErr.Currency)
+			}
+		})
+	}
+}
@@ -27,6 +27,12 @@ const (
 	LabelDNSARecordID         = "mnbr.eu/dns-a-record-id"
 	LabelDNSAAAARecordID      = "mnbr.eu/dns-aaaa-record-id"
 	LabelServerType           = "mnbr.eu/server-type"
+	LabelReady                = "mnbr.eu/ready"
+	LabelTerminationFailures  = "mnbr.eu/termination-failures"
+	LabelTerminationRetry     = "mnbr.eu/termination-retry"
+	LabelStartedBy            = "mnbr.eu/started-by"
+	LabelLocation             = "mnbr.eu/location"
+	LabelOwner                = "mnbr.eu/owner"
 )
 
 var (
@@ -38,26 +44,73 @@ type Control struct {
 	api            *http.Server
 	hclient        *hcloud.Client
 	discordSession *discordgo.Session
+	dns            DNSProvider
+	health         *healthMonitor
+	scheduler      *ttlScheduler
+	allowLinks     *allowLinks
+	firewallLocks  *firewallLocks
+	state          *stateStore
+	confirmations  *confirmations
+	startSlots     *startSlots
+	schedules      *cronSchedules
+	costCache      *costCache
 }
 
 type Config struct {
 	ListenAddr             string
+	PublicURL              string
+	TrustedProxies         []string
 	Location               *hcloud.Location
 	Networks               []*hcloud.Network
 	SSHKeys                []*hcloud.SSHKey
+	DNSProvider            string
 	DNSZoneID              int64
+	DNSZoneName            string
+	DNSRecordSuffix        string
+	DNSRecordTTL           int
+	DNSInternalSuffix      string
+	CloudflareZoneID       string
+	RFC2136Server          string
+	RFC2136TSIGKeyName     string
+	RFC2136TSIGAlgorithm   string
 	DiscordGuildID         string
 	DiscordChannelID       string
 	DiscordAdminRoleID     string
 	DiscordUserRoleID      string
 	DiscordPowerUserRoleID string
+	DiscordAuditChannelID  string
+	ReconcileInterval      time.Duration
+	HealthCheckInterval    time.Duration
+	TerminationWorkers     int
+	TerminationAlertAfter  int
+	AlertWebhookURL        string
+	StateFile              string
+	Budget                 *BudgetConfig
+	ConfirmCostAbove       float64
+	RolePolicies           map[string]*RolePolicy
+	Quotas                 *QuotaConfig
+	MaxServers             int
+	RestrictToOwner        bool
+	ApprovalTimeout        time.Duration
+	VoteQuorum             int
+	VoteWindow             time.Duration
+	WatchEvents            bool
+	EventLeadTime          time.Duration
+	EventGracePeriod       time.Duration
+	Services               map[string]*ServiceConfig
 }
 
 func New(config *Config) (*Control, error) {
 	if config == nil {
 		return nil, errors.New("config can not be nil")
 	}
-	control := &Control{Config: config}
+	if config.ReconcileInterval <= 0 {
+		return nil, errors.New("reconcile interval must be positive")
+	}
+	if config.TerminationWorkers <= 0 {
+		return nil, errors.New("termination workers must be positive")
+	}
+	control := &Control{Config: config, health: newHealthMonitor(), scheduler: newTTLScheduler(), allowLinks: newAllowLinks(), firewallLocks: newFirewallLocks(), confirmations: newConfirmations(), startSlots: &startSlots{}, schedules: newCronSchedules(), costCache: &costCache{}}
 
 	token, ok := os.LookupEnv("HCLOUD_TOKEN")
 	if !ok {
@@ -70,18 +123,35 @@ func New(config *Config) (*Control, error) {
 
 	var err error
 
+	control.state, err = loadStateStore(config.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	control.dns, err = newDNSProvider(config, control.hclient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dns provider: %s", err)
+	}
+
 	control.discordSession, err = discordgo.New("Bot " + os.Getenv("DISCORD_BOT_TOKEN"))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create discord session: %s", err)
 	}
 
 	control.discordSession.AddHandler(control.handleDiscordMessage)
+	control.discordSession.AddHandler(control.handleDiscordInteraction)
 	control.discordSession.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsDirectMessages | discordgo.IntentsGuildMessages)
 
 	gin.SetMode(gin.ReleaseMode)
 	engine := gin.New()
 	engine.Use(gin.Recovery(), gin.Logger())
 
+	// the client ip is used for firewall allow rules and must not be taken from arbitrary forwarding headers
+	err = engine.SetTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set trusted proxies: %s", err)
+	}
+
 	control.api = &http.Server{
 		Addr:    config.ListenAddr,
 		Handler: engine,
@@ -90,14 +160,35 @@ func New(config *Config) (*Control, error) {
 	apiV1 := engine.Group("/api/v1")
 	apiV1.Use(control.Authorize())
 
+	admins := control.RequireRoles(config.DiscordAdminRoleID)
+	powerUsers := control.RequireRoles(config.DiscordAdminRoleID, config.DiscordPowerUserRoleID)
+
 	apiServer := apiV1.Group("/server")
 	apiServer.GET("/", control.ListServers)
-	apiServer.POST("/", control.NewServer)
-	apiServer.POST("/:name/_start", control.StartServer)
-	apiServer.POST("/:name/_reboot", control.RebootServer)
-	apiServer.PUT("/:name/_extend", control.ExtendServer)
-	apiServer.PUT("/:name/_type", control.ChangeServerType)
-	apiServer.DELETE("/:name", control.TerminateServer)
+	apiServer.POST("/", powerUsers, control.NewServer)
+	apiServer.POST("/:name/_start", powerUsers, control.RequireAccess(ActionStart), control.StartServer)
+	apiServer.POST("/:name/_reboot", powerUsers, control.RequireAccess(ActionReboot), control.RebootServer)
+	apiServer.PUT("/:name/_extend", powerUsers, control.RequireAccess(ActionExtend), control.ExtendServer)
+	apiServer.PUT("/:name/_type", powerUsers, control.RequireAccess(ActionType), control.ChangeServerType)
+	apiServer.PUT("/:name/_volume", admins, control.ResizeVolume)
+	apiServer.POST("/:name/_rcon", control.RequireAccess(ActionRCON), control.ExecuteRCON)
+	apiServer.GET("/:name/_health", control.RequireAccess(ActionView), control.GetServerHealth)
+	apiServer.POST("/:name/_allow", powerUsers, control.RequireAccess(ActionAllow), control.AllowClientIP)
+	apiServer.PUT("/:name/_owner", admins, control.TransferOwnership)
+	apiServer.DELETE("/:name", powerUsers, control.RequireAccess(ActionStop), control.TerminateServer)
+	apiServer.DELETE("/:name/_destroy", admins, control.DestroyService)
+
+	apiV1.GET("/costs", control.GetCosts)
+	apiV1.GET("/server-types", powerUsers, control.ListServerTypes)
+	apiV1.GET("/queue", control.ListStartQueue)
+	apiV1.GET("/requests", control.ListApprovalRequests)
+	apiV1.DELETE("/queue/:name", powerUsers, control.DequeueStart)
+	apiV1.GET("/schedules", control.ListSchedules)
+	apiV1.POST("/schedules", powerUsers, control.CreateSchedule)
+	apiV1.DELETE("/schedules/:id", powerUsers, control.DeleteSchedule)
+
+	engine.GET("/allow/:token", control.ConfirmAllowLink)
+	engine.POST("/allow/:token", control.AllowByLink)
 
 	auth := engine.Group("/auth")
 	auth.GET("/", AuthLogin)
@@ -115,6 +206,12 @@ func (control *Control) Run() error {
 		return fmt.Errorf("failed to open discord session: %s", err)
 	}
 
+	// the bot commands keep working without the slash commands
+	err = control.registerSlashCommands()
+	if err != nil {
+		log.Errorf("discord: failed to register slash commands: %s", err)
+	}
+
 	shutdownWG := &sync.WaitGroup{}
 	shutdownChan := make(chan os.Signal, 1)
 	daemonQuitChan := make(chan os.Signal, 1)
@@ -140,61 +237,108 @@ func (control *Control) daemon(quit <-chan os.Signal, wg *sync.WaitGroup) {
 
 	wg.Add(1)
 
-	tickerDuration := 5 * time.Minute
+	stopWorkers := make(chan struct{})
 
-	ticker := time.NewTicker(tickerDuration)
-	defer ticker.Stop()
+	for range control.Config.TerminationWorkers {
+		go control.terminationWorker(stopWorkers)
+	}
 
-	for {
-		select {
-		case <-ticker.C:
-			log.Debug("daemon ticker triggered")
+	control.syncFirewalls(context.Background())
+	control.reconcile(context.Background())
+	control.reconcileSessions(context.Background())
+	control.processStartQueue(context.Background())
+	control.loadSchedules()
 
-			managedServers, err := control.listServers(context.Background())
-			if err != nil {
-				log.Errorf("daemon error: %s", err)
-				break
-			}
-			now := time.Now()
+	reconcileTicker := time.NewTicker(control.Config.ReconcileInterval)
+	defer reconcileTicker.Stop()
 
-			for _, s := range managedServers {
-				log.Debugf("checking ttl for managedServer: %+v", *s)
+	sessionTicker := time.NewTicker(sessionReconcileInterval)
+	defer sessionTicker.Stop()
 
-				if s.Status != hcloud.ServerStatusRunning && s.Status != hcloud.ServerStatusOff {
-					log.Infof("daemon warn: server %s is in status %s, skipping", s.Name, s.Status)
-					continue
-				}
+	var healthTickerChan <-chan time.Time
 
-				ttlStr, ok := s.Labels[LabelTTL]
+	if control.Config.HealthCheckInterval > 0 {
+		healthTicker := time.NewTicker(control.Config.HealthCheckInterval)
+		defer healthTicker.Stop()
 
-				if !ok {
-					log.Errorf("daemon error: ttl label missing on server %s", s.Name)
-					continue
-				}
+		healthTickerChan = healthTicker.C
+	}
 
-				ttlInt, err := strconv.Atoi(ttlStr)
-				if err != nil {
-					log.Errorf("daemon error: failed to parse ttl: %s", err)
-					continue
-				}
+	allowTicker := time.NewTicker(allowCleanupInterval)
+	defer allowTicker.Stop()
 
-				ttl := time.Unix(int64(ttlInt), 0)
+	approvalTicker := time.NewTicker(approvalCleanupInterval)
+	defer approvalTicker.Stop()
 
-				if now.After(ttl) {
-					log.Infof("daemon: server %s is past its ttl, terminating now", s.Name)
+	voteTicker := time.NewTicker(voteCleanupInterval)
+	defer voteTicker.Stop()
 
-					err = control.terminateServer(context.Background(), s.Name)
-					if err != nil {
-						log.Errorf("daemon error: failed to terminate server %s: %s", s.Name, err)
-						continue
-					}
-				}
+	var budgetTickerChan <-chan time.Time
+	var budgetWarningTickerChan <-chan time.Time
 
-				log.Debugf("duration until server %s will reach its ttl: %s -> %s", s.Name, ttl.Sub(now), ttl)
-			}
+	if control.Config.Budget != nil {
+		budgetWarningTicker := time.NewTicker(budgetWarningInterval)
+		defer budgetWarningTicker.Stop()
+
+		budgetWarningTickerChan = budgetWarningTicker.C
+
+		if control.Config.Budget.HardStop {
+			budgetTicker := time.NewTicker(budgetCheckInterval)
+			defer budgetTicker.Stop()
 
-			ticker.Reset(tickerDuration)
+			budgetTickerChan = budgetTicker.C
+		}
+	}
+
+	var queueTickerChan <-chan time.Time
+
+	if control.Config.MaxServers > 0 {
+		queueTicker := time.NewTicker(queueCheckInterval)
+		defer queueTicker.Stop()
+
+		queueTickerChan = queueTicker.C
+	}
+
+	var eventTickerChan <-chan time.Time
+
+	if control.Config.WatchEvents {
+		eventTicker := time.NewTicker(eventCheckInterval)
+		defer eventTicker.Stop()
+
+		eventTickerChan = eventTicker.C
+	}
+
+	for {
+		select {
+		case <-reconcileTicker.C:
+			log.Debug("daemon reconcile ticker triggered")
+
+			control.reconcile(context.Background())
+		case <-sessionTicker.C:
+			control.reconcileSessions(context.Background())
+		case <-healthTickerChan:
+			log.Debug("daemon health ticker triggered")
+
+			control.checkHealthOfServers(context.Background())
+		case <-allowTicker.C:
+			control.removeExpiredAllowRules(context.Background())
+		case <-approvalTicker.C:
+			control.expireApprovals()
+		case <-voteTicker.C:
+			control.expirePolls()
+		case <-budgetTickerChan:
+			control.checkHardStop(context.Background())
+		case <-budgetWarningTickerChan:
+			control.checkBudgetWarnings(context.Background())
+		case <-queueTickerChan:
+			control.processStartQueue(context.Background())
+		case <-eventTickerChan:
+			control.checkScheduledEvents(context.Background())
 		case <-quit:
+			control.scheduler.stop()
+			<-control.schedules.cron.Stop().Done()
+			close(stopWorkers)
+
 			wg.Done()
 
 			log.Info("daemon shutdown complete")
@@ -245,7 +389,127 @@ func (control *Control) listServers(ctx context.Context) ([]*hcloud.Server, erro
 	return managedServers, nil
 }
 
-func (control *Control) newServer(ctx context.Context, req CreateNewServerRequest) (*hcloud.Server, error) {
+func (control *Control) newServer(ctx context.Context, actor string, req CreateNewServerRequest) (*hcloud.Server, error) {
+	blueprintImage, err := control.activeBlueprint(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find active blueprint image for server %s: %s", req.ServerName, err)
+	}
+
+	ttlDuration, err := time.ParseDuration(req.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ttl duration: %s", err)
+	}
+
+	err = control.checkStartQuota(ctx, actor, ttlDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	location := control.location(req.Location)
+
+	err = control.checkServerTypeAllowed(actor, req.ServerType, location.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = control.checkBudget(ctx, actor, req.ServerType, location.Name, ttlDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	err = control.checkCostConfirmation(ctx, req.ServerName, req.ServerType, location.Name, req.TTL, ttlDuration, req.Confirm)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.slotReserved {
+		release, err := control.reserveStartSlot(ctx, &QueuedStart{
+			ServerName:  req.ServerName,
+			ServerType:  req.ServerType,
+			Location:    req.Location,
+			TTL:         req.TTL,
+			New:         true,
+			RequestedBy: actor,
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	ttl := time.Now().Add(ttlDuration)
+
+	publicNet, err := control.servicePublicNet(ctx, req.ServerName, location)
+	if err != nil {
+		return nil, err
+	}
+
+	firewalls, err := control.serviceFirewalls(ctx, req.ServerName)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, userData, err := control.serviceVolumes(ctx, req.ServerName, req.ServerType, location)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{
+		LabelManagedBy: LabelValueMangedByControl,
+		LabelService:   req.ServerName,
+		LabelTTL:       strconv.Itoa(int(ttl.Unix())),
+		LabelStartedBy: actor,
+		LabelOwner:     actor,
+	}
+	// the readiness check runs after the start and sets the label once it is done
+	if control.serviceConfig(req.ServerName).Readiness != nil {
+		labels[LabelReady] = "false"
+	}
+
+	r, _, err := control.hclient.Server.Create(ctx, hcloud.ServerCreateOpts{
+		Name:             req.ServerName,
+		ServerType:       &hcloud.ServerType{Name: req.ServerType},
+		Image:            blueprintImage,
+		Location:         location,
+		StartAfterCreate: new(control.startAfterCreate(req.ServerName)),
+		Labels:           labels,
+		Networks:         control.createNetworks(req.ServerName),
+		SSHKeys:          control.Config.SSHKeys,
+		PublicNet:        publicNet,
+		Firewalls:        firewalls,
+		Volumes:          volumes,
+		UserData:         userData,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server %s: %s", req.ServerName, err)
+	}
+
+	server := r.Server
+
+	if !control.startAfterCreate(req.ServerName) {
+		server, err = control.attachServiceNetworks(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	control.scheduler.schedule(server.Name, ttl)
+
+	control.startSession(ctx, server, actor)
+
+	if control.dnsEnabled() {
+		dnsEntry, err := control.attachDNSRecordToServer(ctx, server)
+		if err != nil {
+			return nil, fmt.Errorf("failed to attach dns record to server %s: %s", req.ServerName, err)
+		}
+
+		setServerDNSPtr(server, dnsEntry)
+	}
+
+	return server, nil
+}
+
+func (control *Control) activeBlueprint(ctx context.Context) (*hcloud.Image, error) {
 	allImages, _, err := control.hclient.Image.List(ctx, hcloud.ImageListOpts{
 		Type: []hcloud.ImageType{hcloud.ImageTypeSnapshot},
 	})
@@ -253,17 +517,28 @@ func (control *Control) newServer(ctx context.Context, req CreateNewServerReques
 		return nil, fmt.Errorf("failed to list images: %s", err)
 	}
 
-	var blueprintImage *hcloud.Image
-
 	for _, image := range allImages {
 		if image.Labels[LabelActiveBlueprint] == "true" {
-			blueprintImage = image
-			break
+			return image, nil
 		}
 	}
 
-	if blueprintImage == nil {
-		return nil, fmt.Errorf("unable to find active blueprint image for server %s", req.ServerName)
+	return nil, errors.New("no image is labeled as active blueprint")
+}
+
+// serviceStartImage returns the image and server type a server of the service is started with.
+func (control *Control) serviceStartImage(ctx context.Context, serviceName string) (*hcloud.Image, string, error) {
+	if control.serviceConfig(serviceName).Volume != nil {
+		return control.volumeStartImage(ctx, serviceName)
+	}
+
+	return control.latestServiceImage(ctx, serviceName)
+}
+
+func (control *Control) startServer(ctx context.Context, actor string, req StartServerRequest) (*hcloud.Server, error) {
+	startImage, serverType, err := control.serviceStartImage(ctx, req.ServerName)
+	if err != nil {
+		return nil, err
 	}
 
 	ttlDuration, err := time.ParseDuration(req.TTL)
@@ -271,52 +546,131 @@ func (control *Control) newServer(ctx context.Context, req CreateNewServerReques
 		return nil, fmt.Errorf("failed to parse ttl duration: %s", err)
 	}
 
-	if ttlDuration > MaxTTL {
-		return nil, errors.New("maximum ttl overflow")
+	err = control.checkStartQuota(ctx, actor, ttlDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := control.serviceLocation(ctx, req.ServerName, startImage)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := control.serviceOwner(ctx, req.ServerName)
+	if err != nil {
+		return nil, err
+	}
+
+	// services from before owners were tracked are owned by whoever starts them first
+	if owner == "" {
+		owner = actor
+	}
+
+	err = control.checkBudget(ctx, actor, serverType, location.Name, ttlDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	err = control.checkCostConfirmation(ctx, req.ServerName, serverType, location.Name, req.TTL, ttlDuration, req.Confirm)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.slotReserved {
+		release, err := control.reserveStartSlot(ctx, &QueuedStart{
+			ServerName:  req.ServerName,
+			TTL:         req.TTL,
+			RequestedBy: actor,
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	ttl := time.Now().Add(ttlDuration)
+
+	publicNet, err := control.servicePublicNet(ctx, req.ServerName, location)
+	if err != nil {
+		return nil, err
+	}
+
+	firewalls, err := control.serviceFirewalls(ctx, req.ServerName)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, userData, err := control.serviceVolumes(ctx, req.ServerName, serverType, location)
+	if err != nil {
+		return nil, err
 	}
 
-	ttl := time.Now().Add(ttlDuration - 5*time.Minute)
+	labels := map[string]string{
+		LabelManagedBy: LabelValueMangedByControl,
+		LabelService:   req.ServerName,
+		LabelTTL:       strconv.Itoa(int(ttl.Unix())),
+		LabelStartedBy: actor,
+		LabelOwner:     owner,
+	}
+	// the readiness check runs after the start and sets the label once it is done
+	if control.serviceConfig(req.ServerName).Readiness != nil {
+		labels[LabelReady] = "false"
+	}
 
 	r, _, err := control.hclient.Server.Create(ctx, hcloud.ServerCreateOpts{
 		Name:             req.ServerName,
-		ServerType:       &hcloud.ServerType{Name: req.ServerType},
-		Image:            blueprintImage,
-		Location:         control.Config.Location,
-		StartAfterCreate: new(true),
-		Labels: map[string]string{
-			LabelManagedBy: LabelValueMangedByControl,
-			LabelService:   req.ServerName,
-			LabelTTL:       strconv.Itoa(int(ttl.Unix())),
-		},
-		Networks: control.Config.Networks,
-		SSHKeys:  control.Config.SSHKeys,
+		ServerType:       &hcloud.ServerType{Name: serverType},
+		Image:            startImage,
+		Location:         location,
+		StartAfterCreate: new(control.startAfterCreate(req.ServerName)),
+		Labels:           labels,
+		Networks:         control.createNetworks(req.ServerName),
+		SSHKeys:          control.Config.SSHKeys,
+		PublicNet:        publicNet,
+		Firewalls:        firewalls,
+		Volumes:          volumes,
+		UserData:         userData,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create server %s: %s", req.ServerName, err)
 	}
 
-	if control.Config.DNSZoneID > 0 {
-		dnsEntry, err := control.attachDNSRecordToServer(ctx, r.Server)
+	server := r.Server
+
+	if !control.startAfterCreate(req.ServerName) {
+		server, err = control.attachServiceNetworks(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	control.scheduler.schedule(server.Name, ttl)
+
+	control.startSession(ctx, server, actor)
+
+	if control.dnsEnabled() {
+		dnsEntry, err := control.attachDNSRecordToServer(ctx, server)
 		if err != nil {
 			return nil, fmt.Errorf("failed to attach dns record to server %s: %s", req.ServerName, err)
 		}
 
-		r.Server.PublicNet.IPv4.DNSPtr = dnsEntry
+		setServerDNSPtr(server, dnsEntry)
 	}
 
-	return r.Server, nil
+	return server, nil
 }
 
-func (control *Control) startServer(ctx context.Context, req StartServerRequest) (*hcloud.Server, error) {
+// latestServiceImage returns the latest snapshot of the service and the server type it was taken from.
+func (control *Control) latestServiceImage(ctx context.Context, serviceName string) (*hcloud.Image, string, error) {
 	allImages, err := control.listImages(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list images: %s", err)
+		return nil, "", fmt.Errorf("failed to list images: %s", err)
 	}
 
 	var latestServiceImage *hcloud.Image
 
 	for _, image := range allImages {
-		if image.Labels[LabelService] == req.ServerName {
+		if image.Labels[LabelService] == serviceName {
 			if latestServiceImage == nil {
 				latestServiceImage = image
 				continue
@@ -328,48 +682,28 @@ func (control *Control) startServer(ctx context.Context, req StartServerRequest)
 		}
 	}
 	if latestServiceImage == nil {
-		return nil, fmt.Errorf("unable to find previous snapshot for server %s", req.ServerName)
+		return nil, "", fmt.Errorf("unable to find previous snapshot for server %s", serviceName)
 	}
 
-	ttlDuration, err := time.ParseDuration(req.TTL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse ttl duration: %s", err)
-	}
+	return latestServiceImage, latestServiceImage.Labels[LabelServerType], nil
+}
 
-	if ttlDuration > MaxTTL {
-		return nil, errors.New("maximum ttl overflow")
+// serviceLocation returns the location the service was running in before, volume services have to stay with their volume.
+func (control *Control) serviceLocation(ctx context.Context, serviceName string, startImage *hcloud.Image) (*hcloud.Location, error) {
+	if control.serviceConfig(serviceName).Volume == nil {
+		return control.location(startImage.Labels[LabelLocation]), nil
 	}
 
-	ttl := time.Now().Add(ttlDuration - 5*time.Minute)
-
-	r, _, err := control.hclient.Server.Create(ctx, hcloud.ServerCreateOpts{
-		Name:             req.ServerName,
-		ServerType:       &hcloud.ServerType{Name: latestServiceImage.Labels[LabelServerType]},
-		Image:            latestServiceImage,
-		Location:         control.Config.Location,
-		StartAfterCreate: new(true),
-		Labels: map[string]string{
-			LabelManagedBy: LabelValueMangedByControl,
-			LabelService:   req.ServerName,
-			LabelTTL:       strconv.Itoa(int(ttl.Unix())),
-		},
-		Networks: control.Config.Networks,
-		SSHKeys:  control.Config.SSHKeys,
-	})
+	volume, err := control.getVolume(ctx, serviceName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create server %s: %s", req.ServerName, err)
+		return nil, err
 	}
 
-	if control.Config.DNSZoneID > 0 {
-		dnsEntry, err := control.attachDNSRecordToServer(ctx, r.Server)
-		if err != nil {
-			return nil, fmt.Errorf("failed to attach dns record to server %s: %s", req.ServerName, err)
-		}
-
-		r.Server.PublicNet.IPv4.DNSPtr = dnsEntry
+	if volume == nil {
+		return nil, fmt.Errorf("unable to find volume for server %s", serviceName)
 	}
 
-	return r.Server, nil
+	return volume.Location, nil
 }
 
 func (control *Control) terminateServer(ctx context.Context, serverName string) error {
@@ -404,6 +738,86 @@ func (control *Control) terminateServer(ctx context.Context, serverName string)
 		return fmt.Errorf("failed to shutdown server %s: %s", serverName, err)
 	}
 
+	if control.serviceConfig(serverName).Volume != nil {
+		err = control.detachVolume(ctx, server)
+	} else {
+		err = control.snapshotServer(ctx, server)
+	}
+	if err != nil {
+		return err
+	}
+
+	// re-get server and check if it's locked until unlocked
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	timeout := time.NewTimer(60 * time.Second)
+	defer timeout.Stop()
+
+	err = func() error {
+		for {
+			select {
+			case <-ticker.C:
+				server, _, err = control.hclient.Server.Get(ctx, serverName)
+				if err != nil {
+					return fmt.Errorf("failed to get server %s by name: %s", serverName, err)
+				}
+
+				if !server.Locked {
+					return nil
+				}
+			case <-timeout.C:
+				return fmt.Errorf("timed out waiting for unlocked server %s", serverName)
+			}
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	deleteResult, _, err := control.hclient.Server.DeleteWithResult(ctx, server)
+	if err != nil {
+		return fmt.Errorf("failed to delete server %s: %s", serverName, err)
+	}
+
+	err = control.hclient.Action.WaitForFunc(ctx, func(update *hcloud.Action) error {
+		log.Infof("delete progress for server %s: %d%%", serverName, update.Progress)
+
+		if update.Progress == 100 {
+			log.Infof("delete complete for server %s", serverName)
+		}
+
+		return nil
+	}, deleteResult.Action)
+	if err != nil {
+		return fmt.Errorf("failed to delete server %s: %s", serverName, err)
+	}
+
+	log.Infof("deleted server %s", serverName)
+
+	control.scheduler.unschedule(serverName)
+
+	// a server started later under the same name starts with a clean health record
+	control.health.remove(serverName)
+
+	control.endSession(serverName, time.Now())
+
+	go control.processStartQueue(context.Background())
+
+	if control.dnsEnabled() {
+		err = control.detachDNSRecordsFromServer(ctx, serverName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// snapshotServer saves the disk of the stopped server and replaces the previous snapshot of the service.
+func (control *Control) snapshotServer(ctx context.Context, server *hcloud.Server) error {
+	serverName := server.Name
+
 	imageResult, _, err := control.hclient.Server.CreateImage(ctx, server, &hcloud.ServerCreateImageOpts{
 		Type:        hcloud.ImageTypeSnapshot,
 		Description: new(fmt.Sprintf("%s/%s", serverName, time.Now().Format(time.RFC3339))),
@@ -411,6 +825,8 @@ func (control *Control) terminateServer(ctx context.Context, serverName string)
 			LabelManagedBy:  LabelValueMangedByControl,
 			LabelService:    serverName,
 			LabelServerType: server.ServerType.Name,
+			LabelLocation:   control.serverLocationName(server),
+			LabelOwner:      server.Labels[LabelOwner],
 		},
 	})
 	if err != nil {
@@ -455,84 +871,63 @@ func (control *Control) terminateServer(ctx context.Context, serverName string)
 		log.Infof("skipping deletion of snapshot")
 	}
 
-	// re-get server and check if it's locked until unlocked
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-
-	timeout := time.NewTimer(60 * time.Second)
-	defer timeout.Stop()
-
-	err = func() error {
-		for {
-			select {
-			case <-ticker.C:
-				server, _, err = control.hclient.Server.Get(ctx, serverName)
-				if err != nil {
-					return fmt.Errorf("failed to get server %s by name: %s", serverName, err)
-				}
+	return nil
+}
 
-				if !server.Locked {
-					return nil
-				}
-			case <-timeout.C:
-				return fmt.Errorf("timed out waiting for unlocked server %s", serverName)
-			}
-		}
-	}()
+// destroyService deletes everything belonging to a terminated service, its snapshots, reserved primary ips,
+// firewall and data volume.
+func (control *Control) destroyService(ctx context.Context, serviceName string) error {
+	server, _, err := control.hclient.Server.Get(ctx, serviceName)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to get server %s by name: %s", serviceName, err)
 	}
 
-	deleteResult, _, err := control.hclient.Server.DeleteWithResult(ctx, server)
-	if err != nil {
-		return fmt.Errorf("failed to delete server %s: %s", serverName, err)
+	if server != nil {
+		return errors.New("can't destroy service while its server is online")
 	}
 
-	err = control.hclient.Action.WaitForFunc(ctx, func(update *hcloud.Action) error {
-		log.Infof("delete progress for server %s: %d%%", serverName, update.Progress)
-
-		if update.Progress == 100 {
-			log.Infof("delete complete for server %s", serverName)
-		}
-
-		return nil
-	}, deleteResult.Action)
+	images, err := control.listImages(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to delete server %s: %s", serverName, err)
+		return fmt.Errorf("failed to list images: %s", err)
 	}
 
-	log.Infof("deleted server %s", serverName)
+	for _, image := range images {
+		if image.Labels[LabelService] != serviceName || image.Labels[LabelActiveBlueprint] == "true" {
+			continue
+		}
 
-	dnsName := serverName + ".svc"
+		err = control.changeImageProtection(ctx, image, hcloud.ImageChangeProtectionOpts{
+			Delete: new(false),
+		})
+		if err != nil {
+			return err
+		}
 
-	deleteSetResultA, _, err := control.hclient.Zone.DeleteRRSet(ctx, &hcloud.ZoneRRSet{
-		Zone: &hcloud.Zone{Name: "mnbr.eu"},
-		Name: dnsName,
-		Type: hcloud.ZoneRRSetTypeA,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to delete dns A record for server %s: %s", serverName, err)
+		_, err = control.hclient.Image.Delete(ctx, image)
+		if err != nil {
+			return fmt.Errorf("failed to delete image %s[%d]: %s", image.Name, image.ID, err)
+		}
+
+		log.Infof("deleted snapshot %s[%d] of service %s", image.Name, image.ID, serviceName)
 	}
 
-	err = control.hclient.Action.WaitFor(ctx, deleteSetResultA.Action)
+	err = control.deletePrimaryIPs(ctx, serviceName)
 	if err != nil {
-		return fmt.Errorf("failed to delete dns A record for server %s: %s", serverName, err)
+		return err
 	}
 
-	deleteSetResultAAAA, _, err := control.hclient.Zone.DeleteRRSet(ctx, &hcloud.ZoneRRSet{
-		Zone: &hcloud.Zone{Name: "mnbr.eu"},
-		Name: dnsName,
-		Type: hcloud.ZoneRRSetTypeAAAA,
-	})
+	err = control.deleteFirewall(ctx, serviceName)
 	if err != nil {
-		return fmt.Errorf("failed to delete dns A record for server %s: %s", serverName, err)
+		return err
 	}
 
-	err = control.hclient.Action.WaitFor(ctx, deleteSetResultAAAA.Action)
+	err = control.deleteVolume(ctx, serviceName)
 	if err != nil {
-		return fmt.Errorf("failed to delete dns A record for server %s: %s", serverName, err)
+		return err
 	}
 
+	log.Infof("destroyed service %s", serviceName)
+
 	return nil
 }
 
@@ -608,7 +1003,7 @@ func (control *Control) rebootServer(ctx context.Context, serverName string) err
 	return nil
 }
 
-func (control *Control) extendServer(ctx context.Context, req ExtendServerRequest) (*time.Time, error) {
+func (control *Control) extendServer(ctx context.Context, actor string, req ExtendServerRequest) (*time.Time, error) {
 	extendDuration, err := time.ParseDuration(req.TTL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse new ttl duration: %s", err)
@@ -623,6 +1018,10 @@ func (control *Control) extendServer(ctx context.Context, req ExtendServerReques
 		return nil, fmt.Errorf("failed to get server: %s", err)
 	}
 
+	if server == nil {
+		return nil, fmt.Errorf("server %s is not running", req.ServerName)
+	}
+
 	ttlStr, ok := server.Labels[LabelTTL]
 	if !ok {
 		return nil, errors.New("missing ttl label")
@@ -636,8 +1035,16 @@ func (control *Control) extendServer(ctx context.Context, req ExtendServerReques
 	currentTTL := time.Unix(int64(ttlInt), 0)
 	extendedTTL := currentTTL.Add(extendDuration)
 
-	if extendedTTL.Sub(time.Now()) > MaxTTL {
-		return nil, errors.New("server cannot be extended beyond maximum ttl")
+	err = control.checkExtendQuota(actor, extendDuration, extendedTTL.Sub(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	if extendDuration > 0 {
+		err = control.checkBudget(ctx, actor, server.ServerType.Name, control.serverLocationName(server), extendDuration)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	server.Labels[LabelTTL] = strconv.Itoa(int(extendedTTL.Unix()))
@@ -647,10 +1054,12 @@ func (control *Control) extendServer(ctx context.Context, req ExtendServerReques
 		return nil, fmt.Errorf("failed to update server: %s", err)
 	}
 
+	control.scheduler.schedule(server.Name, extendedTTL)
+
 	return &extendedTTL, nil
 }
 
-func (control *Control) changeServerType(ctx context.Context, req ChangeServerTypeRequest) error {
+func (control *Control) changeServerType(ctx context.Context, actor string, req ChangeServerTypeRequest) error {
 	server, _, err := control.hclient.Server.Get(ctx, req.ServerName)
 	if err != nil {
 		return fmt.Errorf("failed to get server %s by name: %s", req.ServerName, err)
@@ -660,6 +1069,39 @@ func (control *Control) changeServerType(ctx context.Context, req ChangeServerTy
 		return errors.New("can't change type when server is online")
 	}
 
+	serverType, _, err := control.hclient.ServerType.GetByName(ctx, req.ServerType)
+	if err != nil {
+		return fmt.Errorf("failed to get server type: %s", err)
+	}
+
+	if serverType == nil {
+		return fmt.Errorf("server type %s is invalid", req.ServerType)
+	}
+
+	var startImage *hcloud.Image
+
+	if control.serviceConfig(req.ServerName).Volume == nil {
+		startImage, _, err = control.latestServiceImage(ctx, req.ServerName)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the server will be started in the location of its snapshot or volume
+	location, err := control.serviceLocation(ctx, req.ServerName, startImage)
+	if err != nil {
+		return err
+	}
+
+	err = control.checkServerTypeAllowed(actor, req.ServerType, location.Name)
+	if err != nil {
+		return err
+	}
+
+	if control.serviceConfig(req.ServerName).Volume != nil {
+		return control.changeVolumeServerType(ctx, req)
+	}
+
 	images, err := control.listImages(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list images: %s", err)
@@ -678,15 +1120,6 @@ func (control *Control) changeServerType(ctx context.Context, req ChangeServerTy
 		return fmt.Errorf("image for server %s not found", req.ServerName)
 	}
 
-	serverType, _, err := control.hclient.ServerType.GetByName(ctx, req.ServerType)
-	if err != nil {
-		return fmt.Errorf("failed to get server type: %s", err)
-	}
-
-	if serverType == nil {
-		return fmt.Errorf("server type %s is invalid", req.ServerType)
-	}
-
 	serverImage.Labels[LabelServerType] = req.ServerType
 
 	_, _, err = control.hclient.Image.Update(ctx, serverImage, hcloud.ImageUpdateOpts{Labels: serverImage.Labels})
@@ -698,56 +1131,56 @@ func (control *Control) changeServerType(ctx context.Context, req ChangeServerTy
 }
 
 func (control *Control) attachDNSRecordToServer(ctx context.Context, server *hcloud.Server) (string, error) {
-	dnsName := server.Name + ".svc"
-
-	aResult, _, err := control.hclient.Zone.CreateRRSet(ctx, &hcloud.Zone{ID: control.Config.DNSZoneID}, hcloud.ZoneRRSetCreateOpts{
-		Name: dnsName,
-		Type: hcloud.ZoneRRSetTypeA,
-		TTL:  new(300),
-		Records: []hcloud.ZoneRRSetRecord{
-			{
-				Value:   server.PublicNet.IPv4.IP.String(),
-				Comment: "Managed by mnbcontrol",
-			},
-		},
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to create dns A record: %s", err)
+	ipv4, ipv6 := serverIPv4(server), serverIPv6(server)
+
+	var records []DNSRecord
+
+	for _, record := range control.serviceDNSRecords(server.Name, ipv4, ipv6) {
+		// ipv6 only servers have no address for A records
+		if record.Type == string(hcloud.ZoneRRSetTypeA) && ipv4 == "" {
+			continue
+		}
+
+		records = append(records, record)
 	}
 
-	err = control.hclient.Action.WaitFor(ctx, aResult.Action)
-	if err != nil {
-		return "", fmt.Errorf("failed to create dns A record: %s", err)
+	if dnsConfig := control.serviceConfig(server.Name).DNS; dnsConfig != nil && dnsConfig.Internal {
+		privateIP := serverPrivateIP(server)
+		if privateIP == "" {
+			log.Warnf("server %s has no private ip for its internal dns record", server.Name)
+		} else {
+			records = append(records, DNSRecord{
+				Name:   control.serviceInternalDNSName(server.Name),
+				Type:   string(hcloud.ZoneRRSetTypeA),
+				TTL:    control.serviceDNSTTL(server.Name),
+				Values: []string{privateIP},
+			})
+		}
 	}
 
-	aaaaResult, _, err := control.hclient.Zone.CreateRRSet(ctx, &hcloud.Zone{ID: control.Config.DNSZoneID}, hcloud.ZoneRRSetCreateOpts{
-		Name: dnsName,
-		Type: hcloud.ZoneRRSetTypeAAAA,
-		TTL:  new(300),
-		Records: []hcloud.ZoneRRSetRecord{
-			{
-				Value:   server.PublicNet.IPv6.IP.String() + "1",
-				Comment: "Managed by mnbcontrol",
-			},
-		},
-	})
+	// the records are deleted on stop even if the config changes in the meantime
+	err := control.rememberDNSRecords(server.Name, records)
 	if err != nil {
-		return "", fmt.Errorf("failed to create dns AAAA record: %s", err)
+		return "", err
 	}
 
-	err = control.hclient.Action.WaitFor(ctx, aaaaResult.Action)
-	if err != nil {
-		return "", fmt.Errorf("failed to create dns AAAAA record: %s", err)
+	for _, record := range records {
+		err = control.dns.SetRecord(ctx, record)
+		if err != nil {
+			return "", fmt.Errorf("failed to create dns %s record %s: %s", record.Type, record.Name, err)
+		}
 	}
 
-	dnsFullEntry := dnsName + ".mnbr.eu"
+	dnsFullEntry := control.serviceFQDN(server.Name)
 
-	_, _, err = control.hclient.Server.ChangeDNSPtr(ctx, server, server.PublicNet.IPv4.IP.String(), new(dnsFullEntry))
-	if err != nil {
-		return "", fmt.Errorf("failed to change ipv4 reverse dns pointer for server %s: %s", server.Name, err)
+	if ipv4 != "" {
+		_, _, err := control.hclient.Server.ChangeDNSPtr(ctx, server, ipv4, new(dnsFullEntry))
+		if err != nil {
+			return "", fmt.Errorf("failed to change ipv4 reverse dns pointer for server %s: %s", server.Name, err)
+		}
 	}
 
-	_, _, err = control.hclient.Server.ChangeDNSPtr(ctx, server, server.PublicNet.IPv6.IP.String()+"1", new(dnsFullEntry))
+	_, _, err = control.hclient.Server.ChangeDNSPtr(ctx, server, ipv6, new(dnsFullEntry))
 	if err != nil {
 		return "", fmt.Errorf("failed to change ipv6 reverse dns pointer for server %s: %s", server.Name, err)
 	}
@@ -0,0 +1,160 @@
+package control
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	testGuildID     = "guild"
+	testAdminRoleID = "admin"
+	testPowerRoleID = "power"
+	testUserRoleID  = "user"
+	testLocation    = "fsn1"
+	// testMemberRoute is followed by the user id
+	testMemberRoute   = "GET /api/v9/guilds/" + testGuildID + "/members/"
+	testPricingAnswer = `{"pricing": {
+		"currency": "EUR",
+		"vat_rate": "19.00",
+		"image": {"price_per_gb_month": {"net": "0.0100", "gross": "0.0119"}},
+		"volume": {"price_per_gb_month": {"net": "0.0440", "gross": "0.0524"}},
+		"server_types": [{
+			"id": 1,
+			"name": "cx22",
+			"prices": [{"location": "fsn1", "price_hourly": {"net": "0.0060", "gross": "0.0100"}, "price_monthly": {"net": "3.7900", "gross": "4.5100"}}]
+		}]
+	}}`
+)
+
+// testTransport sends the requests of the discord session to the test server.
+type testTransport struct {
+	target *url.URL
+}
+
+func (transport testTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = transport.target.Scheme
+	req.URL.Host = transport.target.Host
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newTestControl returns a control whose hcloud client and discord session talk to an in-process server,
+// the routes map patterns like "GET /servers" to a JSON answer or an http.HandlerFunc. Unknown routes answer 404.
+func newTestControl(t *testing.T, config *Config, routes map[string]any) *Control {
+	t.Helper()
+
+	mux := http.NewServeMux()
+
+	for pattern, answer := range routes {
+		if handler, ok := answer.(http.HandlerFunc); ok {
+			mux.HandleFunc(pattern, handler)
+			continue
+		}
+
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+
+			if raw, ok := answer.(string); ok {
+				_, _ = w.Write([]byte(raw))
+				return
+			}
+
+			_ = json.NewEncoder(w).Encode(answer)
+		})
+	}
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	target, _ := url.Parse(server.URL)
+
+	session, _ := discordgo.New("Bot test")
+	session.Client = &http.Client{Transport: testTransport{target: target}}
+	session.MaxRestRetries = 0
+
+	if config.DiscordGuildID == "" {
+		config.DiscordGuildID = testGuildID
+	}
+	if config.DiscordAdminRoleID == "" {
+		config.DiscordAdminRoleID = testAdminRoleID
+	}
+	if config.DiscordPowerUserRoleID == "" {
+		config.DiscordPowerUserRoleID = testPowerRoleID
+	}
+	if config.DiscordUserRoleID == "" {
+		config.DiscordUserRoleID = testUserRoleID
+	}
+	if config.Location == nil {
+		config.Location = &hcloud.Location{Name: testLocation}
+	}
+
+	return &Control{
+		Config: config,
+		hclient: hcloud.NewClient(
+			hcloud.WithEndpoint(server.URL),
+			hcloud.WithToken("test"),
+			hcloud.WithRetryOpts(hcloud.RetryOpts{MaxRetries: 0}),
+		),
+		discordSession: session,
+		health:         newHealthMonitor(),
+		scheduler:      newTTLScheduler(),
+		allowLinks:     newAllowLinks(),
+		firewallLocks:  newFirewallLocks(),
+		state:          &stateStore{},
+		confirmations:  newConfirmations(),
+		startSlots:     &startSlots{},
+		schedules:      newCronSchedules(),
+		costCache:      &costCache{},
+	}
+}
+
+// testMember returns the discord answer for a guild member with the roles.
+func testMember(userID string, roles ...string) map[string]any {
+	return map[string]any{"user": map[string]any{"id": userID, "username": userID}, "roles": roles}
+}
+
+// testServer returns the hcloud answer for a managed server with the labels.
+func testServer(id int64, name string, labels map[string]string) map[string]any {
+	allLabels := map[string]string{LabelManagedBy: LabelValueMangedByControl}
+	for key, value := range labels {
+		allLabels[key] = value
+	}
+
+	return map[string]any{
+		"id":          id,
+		"name":        name,
+		"status":      "running",
+		"created":     "2026-01-01T00:00:00Z",
+		"labels":      allLabels,
+		"server_type": map[string]any{"id": 1, "name": "cx22"},
+		"datacenter":  map[string]any{"id": 1, "name": "fsn1-dc14", "location": map[string]any{"id": 1, "name": testLocation}},
+		"location":    map[string]any{"id": 1, "name": testLocation},
+		"public_net": map[string]any{
+			"ipv4": map[string]any{"ip": "192.0.2.1"},
+			"ipv6": map[string]any{"ip": "2001:db8::/64"},
+		},
+	}
+}
+
+// testServers answers listing the servers, getting a server by name filters the list like hcloud does.
+func testServers(servers ...map[string]any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listed := []map[string]any{}
+
+		for _, server := range servers {
+			if name := r.URL.Query().Get("name"); name == "" || server["name"] == name {
+				listed = append(listed, server)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"servers": listed})
+	}
+}
@@ -0,0 +1,399 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	CostMonthLayout = "2006-01"
+
+	sessionReconcileInterval = 5 * time.Minute
+)
+
+// Session is a period in which a server of the service was running, open sessions have no end.
+type Session struct {
+	Service     string     `json:"service"`
+	ServerType  string     `json:"serverType"`
+	StartedBy   string     `json:"startedBy,omitempty"`
+	Start       time.Time  `json:"start"`
+	End         *time.Time `json:"end,omitempty"`
+	HourlyPrice float64    `json:"hourlyPrice"`
+	// MonthlyPrice caps the cost of the server per month like hetzner does
+	MonthlyPrice float64 `json:"monthlyPrice,omitempty"`
+}
+
+// CostReport breaks down the estimated cost of a month, storage is the current monthly rate as its history is unknown.
+type CostReport struct {
+	Month           string             `json:"month"`
+	Currency        string             `json:"currency"`
+	Total           float64            `json:"total"`
+	Services        map[string]float64 `json:"services"`
+	Users           map[string]float64 `json:"users"`
+	ServerTypes     map[string]float64 `json:"serverTypes"`
+	RuntimeHours    map[string]int     `json:"runtimeHours"`
+	SnapshotStorage float64            `json:"snapshotStorage"`
+	VolumeStorage   float64            `json:"volumeStorage"`
+}
+
+// costCache keeps the pricing and the cost report of the current month until the next reconcile,
+// so the budget checks do not query hetzner on every start and every minute.
+type costCache struct {
+	mutex   sync.Mutex
+	pricing *hcloud.Pricing
+	report  *CostReport
+}
+
+func (cache *costCache) reset() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.pricing = nil
+	cache.report = nil
+}
+
+// pricing returns the cached hetzner pricing.
+func (control *Control) pricing(ctx context.Context) (hcloud.Pricing, error) {
+	control.costCache.mutex.Lock()
+	cached := control.costCache.pricing
+	control.costCache.mutex.Unlock()
+
+	if cached != nil {
+		return *cached, nil
+	}
+
+	pricing, _, err := control.hclient.Pricing.Get(ctx)
+	if err != nil {
+		return hcloud.Pricing{}, fmt.Errorf("failed to get pricing: %s", err)
+	}
+
+	control.costCache.mutex.Lock()
+	control.costCache.pricing = &pricing
+	control.costCache.mutex.Unlock()
+
+	return pricing, nil
+}
+
+// currentCostReport returns the cached cost report of the current month for all services, callers must not modify it.
+func (control *Control) currentCostReport(ctx context.Context) (*CostReport, error) {
+	now := time.Now()
+
+	control.costCache.mutex.Lock()
+	cached := control.costCache.report
+	control.costCache.mutex.Unlock()
+
+	if cached != nil && cached.Month == now.Format(CostMonthLayout) {
+		return cached, nil
+	}
+
+	report, err := control.costReport(ctx, now, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	control.costCache.mutex.Lock()
+	control.costCache.report = report
+	control.costCache.mutex.Unlock()
+
+	return report, nil
+}
+
+// startSession records that the server started, the prices are kept as they might change later.
+func (control *Control) startSession(ctx context.Context, server *hcloud.Server, actor string) {
+	var hourlyPrice, monthlyPrice float64
+
+	pricing, err := control.pricing(ctx)
+	if err != nil {
+		log.Errorf("cost error: %s", err)
+	} else {
+		hourlyPrice, monthlyPrice, err = serverTypePrices(pricing, server.ServerType.Name, control.serverLocationName(server))
+		if err != nil {
+			log.Errorf("cost error: %s", err)
+		}
+	}
+
+	control.openSession(&Session{
+		Service:      server.Name,
+		ServerType:   server.ServerType.Name,
+		StartedBy:    actor,
+		Start:        server.Created,
+		HourlyPrice:  hourlyPrice,
+		MonthlyPrice: monthlyPrice,
+	})
+}
+
+// openSession adds the session unless the service already has an open session, the check and the insert
+// happen under the same state lock so a concurrent start and reconcile do not open two sessions.
+func (control *Control) openSession(session *Session) {
+	if session.Start.IsZero() {
+		session.Start = time.Now()
+	}
+
+	err := control.state.update(func(state *persistentState) {
+		for _, existing := range state.Sessions {
+			if existing.Service == session.Service && existing.End == nil {
+				return
+			}
+		}
+
+		state.Sessions = append(state.Sessions, session)
+	})
+	if err != nil {
+		log.Errorf("cost error: failed to save session of server %s: %s", session.Service, err)
+	}
+}
+
+// endSession closes the open session of the service.
+func (control *Control) endSession(serviceName string, end time.Time) {
+	err := control.state.update(func(state *persistentState) {
+		for _, session := range state.Sessions {
+			if session.Service == serviceName && session.End == nil {
+				session.End = new(end)
+			}
+		}
+	})
+	if err != nil {
+		log.Errorf("cost error: failed to save session of server %s: %s", serviceName, err)
+	}
+}
+
+// pruneSessions forgets the sessions which ended before the previous month, they are not reported anymore.
+func (control *Control) pruneSessions(now time.Time) {
+	previousMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+
+	err := control.state.update(func(state *persistentState) {
+		state.Sessions = slices.DeleteFunc(state.Sessions, func(session *Session) bool {
+			return session.End != nil && session.End.Before(previousMonth)
+		})
+	})
+	if err != nil {
+		log.Errorf("cost error: failed to save sessions: %s", err)
+	}
+}
+
+// reconcileSessions opens sessions for servers started outside of mnbcontrol, closes sessions of vanished servers
+// and prunes old sessions.
+func (control *Control) reconcileSessions(ctx context.Context) {
+	control.pruneSessions(time.Now())
+
+	servers, err := control.listServers(ctx)
+	if err != nil {
+		log.Errorf("cost error: %s", err)
+		return
+	}
+
+	running := make(map[string]*hcloud.Server)
+	for _, server := range servers {
+		running[server.Name] = server
+	}
+
+	open := make(map[string]bool)
+	var vanished []string
+
+	control.state.view(func(state *persistentState) {
+		for _, session := range state.Sessions {
+			if session.End != nil {
+				continue
+			}
+			open[session.Service] = true
+			if _, ok := running[session.Service]; !ok {
+				vanished = append(vanished, session.Service)
+			}
+		}
+	})
+
+	for _, serviceName := range vanished {
+		log.Infof("cost: server %s does not exist anymore, closing its session", serviceName)
+		control.endSession(serviceName, time.Now())
+	}
+
+	for serverName, server := range running {
+		if open[serverName] {
+			continue
+		}
+		log.Infof("cost: server %s has no session, opening one", serverName)
+		control.startSession(ctx, server, server.Labels[LabelStartedBy])
+	}
+}
+
+// costReport estimates the cost of the month, every session is billed per started hour up to the monthly price
+// of its server type like hetzner does.
+// Only the services passing the visible filter are included, a nil filter includes all services.
+func (control *Control) costReport(ctx context.Context, month time.Time, visible func(serviceName string) bool) (*CostReport, error) {
+	monthStart := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+
+	pricing, err := control.pricing(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CostReport{
+		Month:        monthStart.Format(CostMonthLayout),
+		Currency:     pricing.Currency,
+		Services:     make(map[string]float64),
+		Users:        make(map[string]float64),
+		ServerTypes:  make(map[string]float64),
+		RuntimeHours: make(map[string]int),
+	}
+
+	var sessions []Session
+
+	control.state.view(func(state *persistentState) {
+		for _, session := range state.Sessions {
+			sessions = append(sessions, *session)
+		}
+	})
+
+	now := time.Now()
+
+	for _, session := range sessions {
+		if visible != nil && !visible(session.Service) {
+			continue
+		}
+
+		start, end := session.Start, now
+		if session.End != nil {
+			end = *session.End
+		}
+
+		if start.Before(monthStart) {
+			start = monthStart
+		}
+		if end.After(monthEnd) {
+			end = monthEnd
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		// sessions without prices use the current ones
+		hourlyPrice, monthlyPrice := session.HourlyPrice, session.MonthlyPrice
+		if hourlyPrice == 0 || monthlyPrice == 0 {
+			currentHourly, currentMonthly, err := serverTypePrices(pricing, session.ServerType, control.Config.Location.Name)
+			if err != nil {
+				log.Errorf("cost error: %s", err)
+			}
+			if hourlyPrice == 0 {
+				hourlyPrice = currentHourly
+			}
+			if monthlyPrice == 0 {
+				monthlyPrice = currentMonthly
+			}
+		}
+
+		hours := int(math.Ceil(end.Sub(start).Hours()))
+		cost := float64(hours) * hourlyPrice
+		if monthlyPrice > 0 {
+			cost = min(cost, monthlyPrice)
+		}
+
+		report.Services[session.Service] += cost
+		report.ServerTypes[session.ServerType] += cost
+		report.RuntimeHours[session.Service] += hours
+		if session.StartedBy != "" {
+			report.Users[session.StartedBy] += cost
+		}
+		report.Total += cost
+	}
+
+	// the storage is only known as it is now, so it is only billed in the current month
+	if now.Before(monthStart) || !now.Before(monthEnd) {
+		return report, nil
+	}
+
+	report.SnapshotStorage, err = control.snapshotStorageCost(ctx, pricing, visible)
+	if err != nil {
+		return nil, err
+	}
+
+	report.VolumeStorage, err = control.volumeStorageCost(ctx, pricing, visible)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Total += report.SnapshotStorage + report.VolumeStorage
+
+	return report, nil
+}
+
+func (control *Control) snapshotStorageCost(ctx context.Context, pricing hcloud.Pricing, visible func(serviceName string) bool) (float64, error) {
+	images, err := control.listImages(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list images: %s", err)
+	}
+
+	price, err := strconv.ParseFloat(pricing.Image.PerGBMonth.Gross, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse snapshot price: %s", err)
+	}
+
+	var size float64
+	for _, image := range images {
+		if visible != nil && !visible(image.Labels[LabelService]) {
+			continue
+		}
+		size += float64(image.ImageSize)
+	}
+
+	return size * price, nil
+}
+
+func (control *Control) volumeStorageCost(ctx context.Context, pricing hcloud.Pricing, visible func(serviceName string) bool) (float64, error) {
+	volumes, err := control.listVolumes(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	price, err := strconv.ParseFloat(pricing.Volume.PerGBMonthly.Gross, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse volume price: %s", err)
+	}
+
+	var size int
+	for _, volume := range volumes {
+		if visible != nil && !visible(volume.Labels[LabelService]) {
+			continue
+		}
+		size += volume.Size
+	}
+
+	return float64(size) * price, nil
+}
+
+// serverTypePrices returns the hourly and the monthly gross price of the server type in the location.
+func serverTypePrices(pricing hcloud.Pricing, serverType, location string) (float64, float64, error) {
+	for _, typePricing := range pricing.ServerTypes {
+		if typePricing.ServerType.Name != serverType {
+			continue
+		}
+
+		for _, locationPricing := range typePricing.Pricings {
+			if locationPricing.Location.Name != location {
+				continue
+			}
+
+			hourly, err := strconv.ParseFloat(locationPricing.Hourly.Gross, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to parse server type price: %s", err)
+			}
+
+			monthly, err := strconv.ParseFloat(locationPricing.Monthly.Gross, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to parse server type price: %s", err)
+			}
+
+			return hourly, monthly, nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("no price for server type %s in location %s", serverType, location)
+}
@@ -0,0 +1,100 @@
+package control
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCostReport(t *testing.T) {
+	sessions := []*Session{
+		// 2.5 hours are billed as 3 started hours
+		{Service: "minecraft", ServerType: "cx22", StartedBy: "alice", Start: time.Date(2026, time.January, 10, 10, 0, 0, 0, time.UTC), End: new(time.Date(2026, time.January, 10, 12, 30, 0, 0, time.UTC)), HourlyPrice: 0.01},
+		// the whole month is capped at the current monthly price as the session has none
+		{Service: "valheim", ServerType: "cx22", StartedBy: "bob", Start: time.Date(2025, time.December, 20, 0, 0, 0, 0, time.UTC), End: new(time.Date(2026, time.February, 10, 0, 0, 0, 0, time.UTC)), HourlyPrice: 0.01},
+		// the prices of the session are kept
+		{Service: "factorio", ServerType: "cx22", Start: time.Date(2026, time.January, 5, 0, 0, 0, 0, time.UTC), End: new(time.Date(2026, time.January, 5, 0, 10, 0, 0, time.UTC)), HourlyPrice: 0.02, MonthlyPrice: 5},
+		// another month
+		{Service: "minecraft", ServerType: "cx22", StartedBy: "alice", Start: time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC), End: new(time.Date(2026, time.February, 1, 5, 0, 0, 0, time.UTC)), HourlyPrice: 0.01},
+	}
+
+	tests := []struct {
+		name         string
+		visible      func(serviceName string) bool
+		total        float64
+		users        map[string]float64
+		runtimeHours map[string]int
+	}{
+		{
+			name:         "all services",
+			total:        4.56,
+			users:        map[string]float64{"alice": 0.03, "bob": 4.51},
+			runtimeHours: map[string]int{"minecraft": 3, "valheim": 744, "factorio": 1},
+		},
+		{
+			name:         "visible services",
+			visible:      func(serviceName string) bool { return serviceName == "minecraft" },
+			total:        0.03,
+			users:        map[string]float64{"alice": 0.03},
+			runtimeHours: map[string]int{"minecraft": 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			control := newTestControl(t, &Config{}, map[string]any{"GET /pricing": testPricingAnswer})
+			control.state.state.Sessions = sessions
+
+			report, err := control.costReport(context.Background(), time.Date(2026, time.January, 15, 0, 0, 0, 0, time.UTC), tt.visible)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !costEqual(report.Total, tt.total) {
+				t.Errorf("expected total %.2f, got %.2f", tt.total, report.Total)
+			}
+			if len(report.Users) != len(tt.users) {
+				t.Errorf("expected users %v, got %v", tt.users, report.Users)
+			}
+			for userID, cost := range tt.users {
+				if !costEqual(report.Users[userID], cost) {
+					t.Errorf("expected %.2f for %s, got %.2f", cost, userID, report.Users[userID])
+				}
+			}
+			if len(report.RuntimeHours) != len(tt.runtimeHours) {
+				t.Errorf("expected runtime hours %v, got %v", tt.runtimeHours, report.RuntimeHours)
+			}
+			for serviceName, hours := range tt.runtimeHours {
+				if report.RuntimeHours[serviceName] != hours {
+					t.Errorf("expected %d hours for %s, got %d", hours, serviceName, report.RuntimeHours[serviceName])
+				}
+			}
+		})
+	}
+}
+
+func TestPruneSessions(t *testing.T) {
+	now := time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC)
+
+	control := &Control{state: &stateStore{state: persistentState{Sessions: []*Session{
+		{Service: "old", Start: time.Date(2026, time.January, 30, 0, 0, 0, 0, time.UTC), End: new(time.Date(2026, time.January, 31, 0, 0, 0, 0, time.UTC))},
+		{Service: "previous month", Start: time.Date(2026, time.January, 31, 0, 0, 0, 0, time.UTC), End: new(time.Date(2026, time.February, 1, 1, 0, 0, 0, time.UTC))},
+		{Service: "open", Start: time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)},
+	}}}}
+
+	control.pruneSessions(now)
+
+	var kept []string
+	for _, session := range control.state.state.Sessions {
+		kept = append(kept, session.Service)
+	}
+
+	if len(kept) != 2 || kept[0] != "previous month" || kept[1] != "open" {
+		t.Errorf("expected the sessions of the previous month and the open session, got %q", kept)
+	}
+}
+
+func costEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
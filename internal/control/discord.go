@@ -4,16 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
-	listServerTemplate = "Status: %s\nType: %v\nDNS: %s\nIPv4: %s\nIPv6: %s\nTTL: %s\n"
+	listServerTemplate  = "Status: %s\nType: %v\nDNS: %s\nIPv4: %s\nIPv6: %s\nTTL: %s\n"
+	maxRCONOutputLength = 1900
 )
 
 var (
@@ -56,26 +60,58 @@ func (control *Control) handleDiscordMessage(s *discordgo.Session, m *discordgo.
 		return
 	}
 
+	control.handleDiscordCommand(member, s, m.Message)
+}
+
+// handleDiscordCommand runs the command of the message, it is also called for messages confirmed later on.
+func (control *Control) handleDiscordCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) {
+	var err error
 	msgLower := strings.ToLower(m.Content)
 	switch {
 	case msgLower == "!help":
-		err = control.handleHelpCommand(member, s, m.Message)
+		err = control.handleHelpCommand(member, s, m)
+	case strings.HasPrefix(msgLower, "!costs"):
+		err = control.handleCostsCommand(member, s, m)
 	case msgLower == "!server list":
-		err = control.handleListServerCommand(member, s, m.Message)
+		err = control.handleListServerCommand(member, s, m)
+	case strings.HasPrefix(msgLower, "!server info"):
+		err = control.handleServerInfoCommand(member, s, m)
 	case strings.HasPrefix(msgLower, "!server start"):
-		err = control.handleStartServerCommand(member, s, m.Message)
+		err = control.handleStartServerCommand(member, s, m)
 	case strings.HasPrefix(msgLower, "!server new"):
-		err = control.handleNewServerCommand(member, s, m.Message)
+		err = control.handleNewServerCommand(member, s, m)
 	case strings.HasPrefix(msgLower, "!server extend"):
-		err = control.handleExtendServerCommand(member, s, m.Message)
+		err = control.handleExtendServerCommand(member, s, m)
 	case strings.HasPrefix(msgLower, "!server prune"):
-		err = control.handlePruneServerCommand(member, s, m.Message)
+		err = control.handlePruneServerCommand(member, s, m)
 	case strings.HasPrefix(msgLower, "!server stop"):
-		err = control.handleTerminateServerCommand(member, s, m.Message)
+		err = control.handleTerminateServerCommand(member, s, m)
 	case strings.HasPrefix(msgLower, "!server reboot"):
-		err = control.handleRebootServerCommand(member, s, m.Message)
+		err = control.handleRebootServerCommand(member, s, m)
+	case strings.HasPrefix(msgLower, "!server destroy"):
+		err = control.handleDestroyServiceCommand(member, s, m)
+	case strings.HasPrefix(msgLower, "!server volume"):
+		err = control.handleResizeVolumeCommand(member, s, m)
 	case strings.HasPrefix(msgLower, "!server type"):
-		err = control.handleChangeServerTypeCommand(member, s, m.Message)
+		err = control.handleChangeServerTypeCommand(member, s, m)
+	case strings.HasPrefix(msgLower, "!server rcon"):
+		err = control.handleRCONCommand(member, s, m)
+	case strings.HasPrefix(msgLower, "!server allow"):
+		err = control.handleAllowCommand(member, s, m)
+	case strings.HasPrefix(msgLower, "!server transfer"):
+		err = control.handleTransferOwnershipCommand(member, s, m)
+	case msgLower == "!server queue":
+		err = control.handleListQueueCommand(member, s, m)
+	case strings.HasPrefix(msgLower, "!server dequeue"):
+		err = control.handleDequeueCommand(member, s, m)
+	case msgLower == "!schedule list":
+		err = control.handleListSchedulesCommand(member, s, m)
+	case strings.HasPrefix(msgLower, "!schedule add"):
+		err = control.handleAddScheduleCommand(member, s, m)
+	case strings.HasPrefix(msgLower, "!schedule remove"):
+		err = control.handleRemoveScheduleCommand(member, s, m)
+	case strings.HasPrefix(msgLower, "!vote "):
+		err = control.handleVoteCommand(member, s, m)
 	default:
 		_, err := s.ChannelMessageSend(m.ChannelID, "I'm sorry, Dave. I'm afraid I can't do that.")
 		if err != nil {
@@ -83,6 +119,14 @@ func (control *Control) handleDiscordMessage(s *discordgo.Session, m *discordgo.
 		}
 		return
 	}
+	if errors.Is(err, ErrBudgetExceeded) || errors.Is(err, ErrNotAllowed) || errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrNotOwner) || errors.Is(err, ErrInvalidOwner) {
+		log.Infof("discord: %s", err)
+		_, err := s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("I'm sorry, Dave. I'm afraid I can't do that: %s", errors.Unwrap(err)))
+		if err != nil {
+			log.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+		}
+		return
+	}
 	if err != nil {
 		log.Errorf("discord: %s", err)
 		_, err := s.ChannelMessageSend(m.ChannelID, "I'm sorry, Dave. I'm afraid I can't do that.")
@@ -111,14 +155,24 @@ func (control *Control) handleHelpCommand(member *discordgo.Member, s *discordgo
 				Value:  "Show this help message",
 				Inline: true,
 			},
+			{
+				Name:   "!costs [month]",
+				Value:  "Show the estimated costs of a month like 2026-01, defaults to the current month",
+				Inline: true,
+			},
 			{
 				Name:   "!server list",
 				Value:  "List all running & terminated server",
 				Inline: true,
 			},
+			{
+				Name:   "!server info [name]",
+				Value:  "Show details and health of a running server",
+				Inline: true,
+			},
 			{
 				Name:   "!server start [name] [ttl]",
-				Value:  "Start a terminated server",
+				Value:  "Start a terminated server, users need the approval of an admin or power user",
 				Inline: true,
 			},
 			{
@@ -142,7 +196,7 @@ func (control *Control) handleHelpCommand(member *discordgo.Member, s *discordgo
 				Inline: true,
 			},
 			{
-				Name:   "!server new [name] [type] [ttl]",
+				Name:   "!server new [name] [type] [ttl] [location]",
 				Value:  "Create a new server",
 				Inline: true,
 			},
@@ -151,6 +205,81 @@ func (control *Control) handleHelpCommand(member *discordgo.Member, s *discordgo
 				Value:  "Change the type of a terminated server",
 				Inline: true,
 			},
+			{
+				Name:   "!server volume [name] [size]",
+				Value:  "Grow the data volume of a server to the given size in GB",
+				Inline: true,
+			},
+			{
+				Name:   "!server destroy [name]",
+				Value:  "Delete all snapshots and reserved IPs of a terminated server",
+				Inline: true,
+			},
+			{
+				Name:   "!server rcon [name] [command]",
+				Value:  "Run a console command on a running server",
+				Inline: true,
+			},
+			{
+				Name:   "!server allow [name]",
+				Value:  "Get a link that opens the admin ports of a server for your IP",
+				Inline: true,
+			},
+			{
+				Name:   "!server queue",
+				Value:  "List the servers waiting for a free slot",
+				Inline: true,
+			},
+			{
+				Name:   "!server dequeue [name]",
+				Value:  "Cancel your queued start of a server",
+				Inline: true,
+			},
+			{
+				Name:   "!server transfer [name] [user]",
+				Value:  "Transfer the ownership of a server",
+				Inline: true,
+			},
+			{
+				Name:   "!schedule list",
+				Value:  "List the scheduled starts and stops",
+				Inline: true,
+			},
+			{
+				Name:   "!schedule add [name] start [ttl] [timezone] [cron]",
+				Value:  "Schedule starts of a server",
+				Inline: true,
+			},
+			{
+				Name:   "!schedule add [name] stop [timezone] [cron]",
+				Value:  "Schedule stops of a server",
+				Inline: true,
+			},
+			{
+				Name:   "!schedule remove [id]",
+				Value:  "Remove a schedule",
+				Inline: true,
+			},
+			{
+				Name:   "!vote start [name] [ttl]",
+				Value:  "Start a vote to start a server",
+				Inline: true,
+			},
+			{
+				Name:   "!vote extend [name] [ttl]",
+				Value:  "Start a vote to extend a server",
+				Inline: true,
+			},
+			{
+				Name:   "Your server types",
+				Value:  choicesLine(control.allowedServerTypes(member)),
+				Inline: false,
+			},
+			{
+				Name:   "Your locations",
+				Value:  choicesLine(control.allowedLocations(member)),
+				Inline: false,
+			},
 		},
 	}
 	_, err := s.ChannelMessageSendEmbed(m.ChannelID, msg)
@@ -172,12 +301,35 @@ func (control *Control) handleListServerCommand(member *discordgo.Member, s *dis
 	if err != nil {
 		return fmt.Errorf("failed to list images for bot: %s", err)
 	}
-	if len(managedServers) == 0 && len(managedImages) == 0 {
+	managedVolumes, err := control.listVolumes(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to list volumes for bot: %s", err)
+	}
+	serviceVolumes := make(map[string]*hcloud.Volume)
+	for _, volume := range managedVolumes {
+		serviceVolumes[volume.Labels[LabelService]] = volume
+	}
+	if len(managedServers) == 0 && len(managedImages) == 0 && len(managedVolumes) == 0 {
 		_, err = s.ChannelMessageSend(m.ChannelID, "No servers available.")
 		if err != nil {
 			return fmt.Errorf("discord: failed to reply to user %s: %s", m.Member.User.Username, err)
 		}
 	}
+	primaryIPs, err := control.listPrimaryIPs(context.Background(), "")
+	if err != nil {
+		return fmt.Errorf("failed to list primary ips for bot: %s", err)
+	}
+	servicePrimaryIPs := make(map[string][]*hcloud.PrimaryIP)
+	for _, primaryIP := range primaryIPs {
+		servicePrimaryIPs[primaryIP.Labels[LabelService]] = append(servicePrimaryIPs[primaryIP.Labels[LabelService]], primaryIP)
+	}
+	var pricing hcloud.Pricing
+	if len(primaryIPs) > 0 {
+		pricing, _, err = control.hclient.Pricing.Get(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to get pricing for bot: %s", err)
+		}
+	}
 	msg := &discordgo.MessageEmbed{
 		Type:        discordgo.EmbedTypeRich,
 		Title:       "Current Servers",
@@ -191,6 +343,9 @@ func (control *Control) handleListServerCommand(member *discordgo.Member, s *dis
 	runningServers := make(map[string]bool)
 	for _, server := range managedServers {
 		runningServers[server.Name] = true
+		if !control.memberMayAccess(member, server.Name, ActionView) {
+			continue
+		}
 		ttlInt, err := strconv.Atoi(server.Labels[LabelTTL])
 		if err != nil {
 			log.Errorf("failed to cast ttl to int64: %s", err)
@@ -203,29 +358,49 @@ func (control *Control) handleListServerCommand(member *discordgo.Member, s *dis
 				listServerTemplate,
 				server.Status,
 				server.ServerType.Name,
-				server.PublicNet.IPv4.DNSPtr,
-				server.PublicNet.IPv4.IP.String(),
-				server.PublicNet.IPv6.IP.String()+"1",
+				valueOrNA(serverDNSPtr(server)),
+				valueOrNA(serverIPv4(server)),
+				valueOrNA(serverIPv6(server)),
 				ttl.Format(time.RFC3339),
-			),
+			) + ownerLine(server.Labels[LabelOwner]) + reservedIPsLine(pricing, servicePrimaryIPs[server.Labels[LabelService]]) + volumeLine(serviceVolumes[server.Labels[LabelService]]),
 			Inline: true,
 		})
 	}
+	// terminated services are known by their snapshots or, in volume mode, by their volumes
+	terminatedServices := make(map[string]string)
+	serviceOwners := make(map[string]string)
 	for _, image := range managedImages {
-		if _, ok := runningServers[image.Labels[LabelService]]; ok {
+		terminatedServices[image.Labels[LabelService]] = image.Labels[LabelServerType]
+		serviceOwners[image.Labels[LabelService]] = image.Labels[LabelOwner]
+	}
+	for _, volume := range managedVolumes {
+		terminatedServices[volume.Labels[LabelService]] = volume.Labels[LabelServerType]
+		serviceOwners[volume.Labels[LabelService]] = volume.Labels[LabelOwner]
+	}
+	for _, serviceName := range slices.Sorted(maps.Keys(terminatedServices)) {
+		if _, ok := runningServers[serviceName]; ok || !control.memberMayAccess(member, serviceName, ActionView) {
 			continue
 		}
+		ipv4, ipv6 := "n/a", "n/a"
+		for _, primaryIP := range servicePrimaryIPs[serviceName] {
+			switch primaryIP.Type {
+			case hcloud.PrimaryIPTypeIPv4:
+				ipv4 = primaryIP.IP.String()
+			case hcloud.PrimaryIPTypeIPv6:
+				ipv6 = ipv6HostAddress(primaryIP.IP).String()
+			}
+		}
 		msg.Fields = append(msg.Fields, &discordgo.MessageEmbedField{
-			Name: image.Labels[LabelService],
+			Name: serviceName,
 			Value: fmt.Sprintf(
 				listServerTemplate,
 				"terminated",
-				image.Labels[LabelServerType],
+				terminatedServices[serviceName],
 				"n/a",
+				ipv4,
+				ipv6,
 				"n/a",
-				"n/a",
-				"n/a",
-			),
+			) + ownerLine(serviceOwners[serviceName]) + reservedIPsLine(pricing, servicePrimaryIPs[serviceName]) + volumeLine(serviceVolumes[serviceName]),
 			Inline: true,
 		})
 	}
@@ -236,8 +411,145 @@ func (control *Control) handleListServerCommand(member *discordgo.Member, s *dis
 	return nil
 }
 
+func (control *Control) handleServerInfoCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID, control.Config.DiscordUserRoleID) {
+		return ErrUnauthorized
+	}
+	contentSplit := strings.Split(strings.ToLower(m.Content), " ")
+	if len(contentSplit) != 3 {
+		return ErrIllegalArguments
+	}
+	err := control.checkAccess(member, contentSplit[2], ActionView)
+	if err != nil {
+		return fmt.Errorf("failed to get server for bot: %w", err)
+	}
+	server, _, err := control.hclient.Server.Get(context.Background(), contentSplit[2])
+	if err != nil {
+		return fmt.Errorf("failed to get server for bot: %s", err)
+	}
+	if server == nil || server.Labels[LabelManagedBy] != LabelValueMangedByControl {
+		_, err = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Server %s is not running.", contentSplit[2]))
+		if err != nil {
+			return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+		}
+		return nil
+	}
+	ttlInt, err := strconv.Atoi(server.Labels[LabelTTL])
+	if err != nil {
+		return fmt.Errorf("failed to cast ttl to int64: %s", err)
+	}
+	ready, ok := server.Labels[LabelReady]
+	if !ok {
+		ready = "n/a"
+	}
+	msg := &discordgo.MessageEmbed{
+		Type:  discordgo.EmbedTypeRich,
+		Title: server.Name,
+		Description: fmt.Sprintf(
+			listServerTemplate+"Ready: %s\n",
+			server.Status,
+			server.ServerType.Name,
+			valueOrNA(serverDNSPtr(server)),
+			valueOrNA(serverIPv4(server)),
+			valueOrNA(serverIPv6(server)),
+			time.Unix(int64(ttlInt), 0).Format(time.RFC3339),
+			ready,
+		) + ownerLine(server.Labels[LabelOwner]),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "I am putting myself to the fullest possible use, which is all I think that any conscious entity can ever hope to do.",
+		},
+	}
+	if health := control.health.status(server.Name); health != nil {
+		var history strings.Builder
+		for _, record := range health.History {
+			if record.Healthy {
+				history.WriteString("🟢")
+			} else {
+				history.WriteString("🔴")
+			}
+		}
+		healthValue := fmt.Sprintf("Consecutive failures: %d\nHistory: %s\n", health.ConsecutiveFailures, history.String())
+		if health.LastReboot != nil {
+			healthValue += fmt.Sprintf("Last automatic reboot: %s\n", health.LastReboot.Format(time.RFC3339))
+		}
+		if n := len(health.History); n > 0 && !health.History[n-1].Healthy {
+			healthValue += fmt.Sprintf("Last error: %s\n", health.History[n-1].Error)
+		}
+		msg.Fields = append(msg.Fields, &discordgo.MessageEmbedField{
+			Name:  "Health",
+			Value: healthValue,
+		})
+	}
+	_, err = s.ChannelMessageSendEmbed(m.ChannelID, msg)
+	if err != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+	}
+	return nil
+}
+
+func (control *Control) handleCostsCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID, control.Config.DiscordUserRoleID) {
+		return ErrUnauthorized
+	}
+	month := time.Now()
+	contentSplit := strings.Split(strings.ToLower(m.Content), " ")
+	switch len(contentSplit) {
+	case 1:
+	case 2:
+		var err error
+		month, err = time.Parse(CostMonthLayout, contentSplit[1])
+		if err != nil {
+			return ErrIllegalArguments
+		}
+	default:
+		return ErrIllegalArguments
+	}
+	report, err := control.costReport(context.Background(), month, control.viewFilter(member))
+	if err != nil {
+		return fmt.Errorf("failed to create cost report for bot: %s", err)
+	}
+	services := make(map[string]string)
+	for serviceName, cost := range report.Services {
+		services[serviceName] = fmt.Sprintf("%.2f %s (%dh)", cost, report.Currency, report.RuntimeHours[serviceName])
+	}
+	users := make(map[string]string)
+	for userID, cost := range report.Users {
+		users["<@"+userID+">"] = fmt.Sprintf("%.2f %s", cost, report.Currency)
+	}
+	serverTypes := make(map[string]string)
+	for serverType, cost := range report.ServerTypes {
+		serverTypes[serverType] = fmt.Sprintf("%.2f %s", cost, report.Currency)
+	}
+	msg := &discordgo.MessageEmbed{
+		Type:        discordgo.EmbedTypeRich,
+		Title:       "Costs " + report.Month,
+		Description: fmt.Sprintf("Total: %.2f %s", report.Total, report.Currency),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "I am putting myself to the fullest possible use, which is all I think that any conscious entity can ever hope to do.",
+		},
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Servers", Value: costLines(services)},
+			{Name: "Users", Value: costLines(users)},
+			{Name: "Server Types", Value: costLines(serverTypes)},
+			{
+				Name: "Storage per Month",
+				Value: fmt.Sprintf(
+					"Snapshots: %.2f %s\nVolumes: %.2f %s",
+					report.SnapshotStorage, report.Currency,
+					report.VolumeStorage, report.Currency,
+				),
+			},
+		},
+	}
+	_, err = s.ChannelMessageSendEmbed(m.ChannelID, msg)
+	if err != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+	}
+	return nil
+}
+
 func (control *Control) handleStartServerCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
-	if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID) {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID, control.Config.DiscordUserRoleID) {
 		return ErrUnauthorized
 	}
 	var req StartServerRequest
@@ -252,24 +564,42 @@ func (control *Control) handleStartServerCommand(member *discordgo.Member, s *di
 	default:
 		return ErrIllegalArguments
 	}
-	server, err := control.startServer(context.Background(), req)
+	err := control.checkAccess(member, req.ServerName, ActionStart)
 	if err != nil {
-		return fmt.Errorf("failed to start server for bot: %s", err)
+		return fmt.Errorf("failed to start server for bot: %w", err)
 	}
-	_, err = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
-		"Server %s started with DNS %s. It will run for %s",
+	// regular users have to ask an admin or power user for approval
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID) {
+		err = control.requestApproval(s, m, req)
+		if err != nil {
+			return fmt.Errorf("failed to request approval for bot: %w", err)
+		}
+		return nil
+	}
+	req.Confirm = control.confirmations.isConfirmed(m.ID)
+	server, err := control.startServer(context.Background(), m.Author.ID, req)
+	var confirmErr *CostConfirmationError
+	if errors.As(err, &confirmErr) {
+		return control.requestConfirmation(member, s, m, confirmErr)
+	}
+	var queuedErr *QueuedError
+	if errors.As(err, &queuedErr) {
+		return control.replyQueued(s, m, queuedErr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start server for bot: %w", err)
+	}
+	control.announceWhenReady(server, control.replier(s, m), fmt.Sprintf(
+		"Server %s is ready with DNS %s. It will run for %s",
 		server.Name,
-		server.PublicNet.IPv4.DNSPtr,
+		serverDNSPtr(server),
 		req.TTL,
 	))
-	if err != nil {
-		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
-	}
 	return nil
 }
 
 func (control *Control) handleNewServerCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
-	if !memberHasRole(member, control.Config.DiscordAdminRoleID) {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID) {
 		return ErrUnauthorized
 	}
 	var req CreateNewServerRequest
@@ -287,22 +617,37 @@ func (control *Control) handleNewServerCommand(member *discordgo.Member, s *disc
 		req.ServerName = contentSplit[2]
 		req.ServerType = contentSplit[3]
 		req.TTL = contentSplit[4]
+	case 6:
+		req.ServerName = contentSplit[2]
+		req.ServerType = contentSplit[3]
+		req.TTL = contentSplit[4]
+		req.Location = contentSplit[5]
 	default:
 		return ErrIllegalArguments
 	}
-	server, err := control.newServer(context.Background(), req)
+	err := control.checkAccess(member, req.ServerName, ActionStart)
 	if err != nil {
-		return fmt.Errorf("failed to create new server for bot: %s", err)
+		return fmt.Errorf("failed to create new server for bot: %w", err)
 	}
-	_, err = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
+	req.Confirm = control.confirmations.isConfirmed(m.ID)
+	server, err := control.newServer(context.Background(), m.Author.ID, req)
+	var confirmErr *CostConfirmationError
+	if errors.As(err, &confirmErr) {
+		return control.requestConfirmation(member, s, m, confirmErr)
+	}
+	var queuedErr *QueuedError
+	if errors.As(err, &queuedErr) {
+		return control.replyQueued(s, m, queuedErr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create new server for bot: %w", err)
+	}
+	control.announceWhenReady(server, control.replier(s, m), fmt.Sprintf(
 		"Created new server %s with DNS %s. It will run for %s",
 		server.Name,
-		server.Name+".svc.mnbr.eu",
+		control.serviceFQDN(server.Name),
 		req.TTL,
 	))
-	if err != nil {
-		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
-	}
 	return nil
 }
 
@@ -314,14 +659,22 @@ func (control *Control) handleExtendServerCommand(member *discordgo.Member, s *d
 	if len(contentSplit) != 4 {
 		return ErrIllegalArguments
 	}
+	err := control.checkAccess(member, contentSplit[2], ActionExtend)
+	if err != nil {
+		return fmt.Errorf("failed to extend server for bot: %w", err)
+	}
+	err = control.checkServerOwner(context.Background(), m.Author.ID, contentSplit[2])
+	if err != nil {
+		return fmt.Errorf("failed to extend server for bot: %w", err)
+	}
 	req := ExtendServerRequest{
 		ServerName: contentSplit[2],
 		TTL:        contentSplit[3],
 		Inverse:    false,
 	}
-	extendedTTL, err := control.extendServer(context.Background(), req)
+	extendedTTL, err := control.extendServer(context.Background(), m.Author.ID, req)
 	if err != nil {
-		return fmt.Errorf("failed to extend server for bot: %s", err)
+		return fmt.Errorf("failed to extend server for bot: %w", err)
 	}
 	_, err = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
 		"Server %s has been extended until %s",
@@ -342,12 +695,20 @@ func (control *Control) handlePruneServerCommand(member *discordgo.Member, s *di
 	if len(contentSplit) != 4 {
 		return ErrIllegalArguments
 	}
+	err := control.checkAccess(member, contentSplit[2], ActionExtend)
+	if err != nil {
+		return fmt.Errorf("failed to prune server for bot: %w", err)
+	}
+	err = control.checkServerOwner(context.Background(), m.Author.ID, contentSplit[2])
+	if err != nil {
+		return fmt.Errorf("failed to prune server for bot: %w", err)
+	}
 	req := ExtendServerRequest{
 		ServerName: contentSplit[2],
 		TTL:        contentSplit[3],
 		Inverse:    true,
 	}
-	extendedTTL, err := control.extendServer(context.Background(), req)
+	extendedTTL, err := control.extendServer(context.Background(), m.Author.ID, req)
 	if err != nil {
 		return fmt.Errorf("failed to prune server for bot: %s", err)
 	}
@@ -370,7 +731,15 @@ func (control *Control) handleRebootServerCommand(member *discordgo.Member, s *d
 	if len(contentSplit) != 3 {
 		return ErrIllegalArguments
 	}
-	_, err := s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
+	err := control.checkAccess(member, contentSplit[2], ActionReboot)
+	if err != nil {
+		return fmt.Errorf("failed to reboot server for bot: %w", err)
+	}
+	err = control.checkServerOwner(context.Background(), m.Author.ID, contentSplit[2])
+	if err != nil {
+		return fmt.Errorf("failed to reboot server for bot: %w", err)
+	}
+	_, err = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
 		"Server %s will be rebooted, this might take a while",
 		contentSplit[2],
 	))
@@ -399,7 +768,15 @@ func (control *Control) handleTerminateServerCommand(member *discordgo.Member, s
 	if len(contentSplit) != 3 {
 		return ErrIllegalArguments
 	}
-	_, err := s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
+	err := control.checkAccess(member, contentSplit[2], ActionStop)
+	if err != nil {
+		return fmt.Errorf("failed to terminate server for bot: %w", err)
+	}
+	err = control.checkServerOwner(context.Background(), m.Author.ID, contentSplit[2])
+	if err != nil {
+		return fmt.Errorf("failed to terminate server for bot: %w", err)
+	}
+	_, err = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
 		"Server %s will be terminated, this might take a while",
 		contentSplit[2],
 	))
@@ -421,7 +798,7 @@ func (control *Control) handleTerminateServerCommand(member *discordgo.Member, s
 }
 
 func (control *Control) handleChangeServerTypeCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
-	if !memberHasRole(member, control.Config.DiscordAdminRoleID) {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID) {
 		return ErrUnauthorized
 	}
 	contentSplit := strings.Split(strings.ToLower(m.Content), " ")
@@ -432,9 +809,13 @@ func (control *Control) handleChangeServerTypeCommand(member *discordgo.Member,
 		ServerName: contentSplit[2],
 		ServerType: contentSplit[3],
 	}
-	err := control.changeServerType(context.Background(), req)
+	err := control.checkAccess(member, req.ServerName, ActionType)
+	if err != nil {
+		return fmt.Errorf("failed to change server type for bot: %w", err)
+	}
+	err = control.changeServerType(context.Background(), m.Author.ID, req)
 	if err != nil {
-		return fmt.Errorf("failed to change server type for bot: %s", err)
+		return fmt.Errorf("failed to change server type for bot: %w", err)
 	}
 	_, err = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
 		"Server %s is now of type %s",
@@ -447,6 +828,401 @@ func (control *Control) handleChangeServerTypeCommand(member *discordgo.Member,
 	return nil
 }
 
+func (control *Control) handleResizeVolumeCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID) {
+		return ErrUnauthorized
+	}
+	contentSplit := strings.Split(strings.ToLower(m.Content), " ")
+	if len(contentSplit) != 4 {
+		return ErrIllegalArguments
+	}
+	size, err := strconv.Atoi(contentSplit[3])
+	if err != nil {
+		return ErrIllegalArguments
+	}
+	req := ResizeVolumeRequest{
+		ServerName: contentSplit[2],
+		Size:       size,
+	}
+	err = control.resizeVolume(context.Background(), req)
+	if err != nil {
+		return fmt.Errorf("failed to resize volume for bot: %s", err)
+	}
+	control.audit(m.Author.ID, "resize volume", req.ServerName, fmt.Sprintf("%d GB", req.Size))
+	_, err = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
+		"Volume of server %s has been resized to %d GB",
+		req.ServerName,
+		req.Size,
+	))
+	if err != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+	}
+	return nil
+}
+
+func (control *Control) handleDestroyServiceCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID) {
+		return ErrUnauthorized
+	}
+	contentSplit := strings.Split(strings.ToLower(m.Content), " ")
+	if len(contentSplit) != 3 {
+		return ErrIllegalArguments
+	}
+	control.audit(m.Author.ID, "destroy", contentSplit[2], "")
+	err := control.destroyService(context.Background(), contentSplit[2])
+	if err != nil {
+		return fmt.Errorf("failed to destroy service for bot: %s", err)
+	}
+	_, err = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
+		"Server %s has been destroyed",
+		contentSplit[2],
+	))
+	if err != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+	}
+	return nil
+}
+
+func (control *Control) handleRCONCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
+	// keep the original case, console commands and their arguments may be case-sensitive
+	contentSplit := strings.SplitN(m.Content, " ", 4)
+	if len(contentSplit) != 4 {
+		return ErrIllegalArguments
+	}
+	req := RCONRequest{
+		ServerName: strings.ToLower(contentSplit[2]),
+		Command:    contentSplit[3],
+	}
+	roles := []string{control.Config.DiscordAdminRoleID}
+	if rconConfig := control.serviceConfig(req.ServerName).RCON; rconConfig != nil {
+		roles = append(roles, rconConfig.RoleIDs...)
+	}
+	if !memberHasRole(member, roles...) {
+		return ErrUnauthorized
+	}
+	err := control.checkAccess(member, req.ServerName, ActionRCON)
+	if err != nil {
+		return fmt.Errorf("failed to execute rcon command for bot: %w", err)
+	}
+	output, err := control.executeRCON(context.Background(), m.Author.ID, req)
+	if err != nil {
+		return fmt.Errorf("failed to execute rcon command for bot: %s", err)
+	}
+	if output == "" {
+		output = "(no output)"
+	}
+	_, err = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```\n%s\n```", truncateRCONOutput(output, maxRCONOutputLength)))
+	if err != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+	}
+	return nil
+}
+
+func (control *Control) handleAllowCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID) {
+		return ErrUnauthorized
+	}
+	contentSplit := strings.Split(strings.ToLower(m.Content), " ")
+	if len(contentSplit) != 3 {
+		return ErrIllegalArguments
+	}
+	err := control.checkAccess(member, contentSplit[2], ActionAllow)
+	if err != nil {
+		return fmt.Errorf("failed to create allow link for bot: %w", err)
+	}
+	token, err := control.allowLinks.create(contentSplit[2], m.Author.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create allow link for bot: %s", err)
+	}
+	// the link is personal, so it is sent as direct message
+	channel, err := s.UserChannelCreate(m.Author.ID)
+	if err != nil {
+		return fmt.Errorf("discord: failed to create direct message channel for user %s: %s", m.Author.Username, err)
+	}
+	_, err = s.ChannelMessageSend(channel.ID, fmt.Sprintf(
+		"Open <%s/allow/%s> in your browser within %s and confirm to allow your IP on server %s. The link can only be used once.",
+		strings.TrimSuffix(control.Config.PublicURL, "/"),
+		token,
+		allowLinkTTL,
+		contentSplit[2],
+	))
+	if err != nil {
+		return fmt.Errorf("discord: failed to send direct message to user %s: %s", m.Author.Username, err)
+	}
+	if channel.ID == m.ChannelID {
+		return nil
+	}
+	_, err = s.ChannelMessageSend(m.ChannelID, "I've sent you a link via direct message.")
+	if err != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+	}
+	return nil
+}
+
+func (control *Control) handleTransferOwnershipCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID) {
+		return ErrUnauthorized
+	}
+	contentSplit := strings.Split(strings.ToLower(m.Content), " ")
+	if len(contentSplit) != 4 {
+		return ErrIllegalArguments
+	}
+	req := TransferOwnershipRequest{
+		ServerName: contentSplit[2],
+		Owner:      strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(contentSplit[3], "<@"), "!"), ">"),
+	}
+	control.audit(m.Author.ID, "transfer", req.ServerName, req.Owner)
+	err := control.transferOwnership(context.Background(), req)
+	if errors.Is(err, ErrInvalidOwner) {
+		return fmt.Errorf("failed to transfer ownership for bot: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to transfer ownership for bot: %s", err)
+	}
+	_, err = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
+		"Server %s is now owned by <@%s>",
+		req.ServerName,
+		req.Owner,
+	))
+	if err != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+	}
+	return nil
+}
+
+func (control *Control) replyQueued(s *discordgo.Session, m *discordgo.Message, queuedErr *QueuedError) error {
+	_, err := s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
+		"All %d server slots are in use, server %s is queued at position %d. It starts automatically once a slot is free, cancel with `!server dequeue %s`",
+		control.Config.MaxServers,
+		queuedErr.Entry.ServerName,
+		queuedErr.Entry.Position,
+		queuedErr.Entry.ServerName,
+	))
+	if err != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+	}
+	return nil
+}
+
+func (control *Control) handleListQueueCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID, control.Config.DiscordUserRoleID) {
+		return ErrUnauthorized
+	}
+	queue := slices.DeleteFunc(control.startQueue(), func(entry *QueuedStart) bool {
+		return !control.memberMayAccess(member, entry.ServerName, ActionView)
+	})
+	if len(queue) == 0 {
+		_, err := s.ChannelMessageSend(m.ChannelID, "No servers are queued")
+		if err != nil {
+			return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+		}
+		return nil
+	}
+	msg := &discordgo.MessageEmbed{
+		Type:        discordgo.EmbedTypeRich,
+		Title:       "Start Queue",
+		Description: fmt.Sprintf("%d server slots", control.Config.MaxServers),
+		Fields:      []*discordgo.MessageEmbedField{},
+	}
+	for _, entry := range queue {
+		msg.Fields = append(msg.Fields, &discordgo.MessageEmbedField{
+			Name: fmt.Sprintf("%d. %s", entry.Position, entry.ServerName),
+			Value: fmt.Sprintf(
+				"Requested by <@%s> %s ago for %s",
+				entry.RequestedBy,
+				time.Since(entry.Queued).Round(time.Minute),
+				entry.TTL,
+			),
+		})
+	}
+	_, err := s.ChannelMessageSendEmbed(m.ChannelID, msg)
+	if err != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+	}
+	return nil
+}
+
+func (control *Control) handleDequeueCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID) {
+		return ErrUnauthorized
+	}
+	contentSplit := strings.Split(strings.ToLower(m.Content), " ")
+	if len(contentSplit) != 3 {
+		return ErrIllegalArguments
+	}
+	err := control.dequeueStart(contentSplit[2], m.Author.ID, memberHasRole(member, control.Config.DiscordAdminRoleID))
+	if err != nil {
+		return fmt.Errorf("failed to dequeue server for bot: %s", err)
+	}
+	_, err = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
+		"Cancelled the queued start of server %s",
+		contentSplit[2],
+	))
+	if err != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+	}
+	return nil
+}
+
+func (control *Control) handleListSchedulesCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID, control.Config.DiscordUserRoleID) {
+		return ErrUnauthorized
+	}
+	schedules := control.visibleSchedules(member)
+	if len(schedules) == 0 {
+		_, err := s.ChannelMessageSend(m.ChannelID, "No schedules exist")
+		if err != nil {
+			return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+		}
+		return nil
+	}
+	msg := &discordgo.MessageEmbed{
+		Type:   discordgo.EmbedTypeRich,
+		Title:  "Schedules",
+		Fields: []*discordgo.MessageEmbedField{},
+	}
+	for _, schedule := range schedules {
+		action := schedule.Action
+		if schedule.Action == ActionStart {
+			action = fmt.Sprintf("%s for %s", schedule.Action, schedule.TTL)
+		}
+		next := "never"
+		if schedule.Next != nil {
+			next = schedule.Next.Format(time.RFC3339)
+		}
+		msg.Fields = append(msg.Fields, &discordgo.MessageEmbedField{
+			Name: fmt.Sprintf("%s: %s", schedule.ServerName, schedule.ID),
+			Value: fmt.Sprintf(
+				"%s at `%s` %s\nNext: %s\nAdded by <@%s>",
+				action,
+				schedule.Cron,
+				schedule.TimeZone,
+				next,
+				schedule.CreatedBy,
+			),
+		})
+	}
+	_, err := s.ChannelMessageSendEmbed(m.ChannelID, msg)
+	if err != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+	}
+	return nil
+}
+
+func (control *Control) handleAddScheduleCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID) {
+		return ErrUnauthorized
+	}
+	// the time zone is case sensitive, so the content is not lowercased
+	contentSplit := strings.Fields(m.Content)
+	if len(contentSplit) < 5 {
+		return ErrIllegalArguments
+	}
+	req := CreateScheduleRequest{
+		ServerName: strings.ToLower(contentSplit[2]),
+		Action:     strings.ToLower(contentSplit[3]),
+	}
+	switch {
+	case req.Action == ActionStart && len(contentSplit) > 6:
+		req.TTL, req.TimeZone, req.Cron = contentSplit[4], contentSplit[5], strings.Join(contentSplit[6:], " ")
+	case req.Action == ActionStop && len(contentSplit) > 5:
+		req.TimeZone, req.Cron = contentSplit[4], strings.Join(contentSplit[5:], " ")
+	default:
+		return ErrIllegalArguments
+	}
+	err := control.checkAccess(member, req.ServerName, req.Action)
+	if err != nil {
+		return fmt.Errorf("failed to add schedule for bot: %w", err)
+	}
+	schedule, err := control.addSchedule(context.Background(), m.Author.ID, req)
+	if err != nil {
+		return fmt.Errorf("failed to add schedule for bot: %w", err)
+	}
+	control.audit(m.Author.ID, "schedule", schedule.ServerName, fmt.Sprintf("%s at %s %s", schedule.Action, schedule.Cron, schedule.TimeZone))
+	_, err = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
+		"Added schedule %s to %s server %s at `%s` %s",
+		schedule.ID,
+		schedule.Action,
+		schedule.ServerName,
+		schedule.Cron,
+		schedule.TimeZone,
+	))
+	if err != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+	}
+	return nil
+}
+
+func (control *Control) handleRemoveScheduleCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID) {
+		return ErrUnauthorized
+	}
+	contentSplit := strings.Split(strings.ToLower(m.Content), " ")
+	if len(contentSplit) != 3 {
+		return ErrIllegalArguments
+	}
+	err := control.checkScheduleAccess(member, contentSplit[2])
+	if err != nil {
+		return fmt.Errorf("failed to remove schedule for bot: %w", err)
+	}
+	schedule, err := control.removeSchedule(contentSplit[2])
+	if err != nil {
+		return fmt.Errorf("failed to remove schedule for bot: %s", err)
+	}
+	control.audit(m.Author.ID, "unschedule", schedule.ServerName, schedule.ID)
+	_, err = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
+		"Removed schedule %s of server %s",
+		schedule.ID,
+		schedule.ServerName,
+	))
+	if err != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+	}
+	return nil
+}
+
+// reservedIPsLine describes the reserved primary ips of a service for the server list.
+func reservedIPsLine(pricing hcloud.Pricing, primaryIPs []*hcloud.PrimaryIP) string {
+	if len(primaryIPs) == 0 {
+		return ""
+	}
+	cost, err := primaryIPMonthlyCost(pricing, primaryIPs)
+	if err != nil {
+		log.Errorf("failed to calculate primary ip cost: %s", err)
+		return "Reserved IPs: yes\n"
+	}
+	return fmt.Sprintf("Reserved IPs: %.2f %s/month\n", cost, pricing.Currency)
+}
+
+// costLines renders one line per entry sorted by name, embed fields must not be empty.
+func costLines(costs map[string]string) string {
+	if len(costs) == 0 {
+		return "n/a"
+	}
+	var lines strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(costs)) {
+		lines.WriteString(fmt.Sprintf("%s: %s\n", name, costs[name]))
+	}
+	return lines.String()
+}
+
+// choicesLine lists the allowed choices, nil means there is no restriction.
+func choicesLine(choices []string) string {
+	if choices == nil {
+		return "any"
+	}
+
+	return strings.Join(choices, ", ")
+}
+
+// volumeLine describes the data volume of a service for the server list.
+func volumeLine(volume *hcloud.Volume) string {
+	if volume == nil {
+		return ""
+	}
+	return fmt.Sprintf("Volume: %d GB\n", volume.Size)
+}
+
 func memberHasRole(member *discordgo.Member, roles ...string) bool {
 	for _, givenRole := range roles {
 		for _, r := range member.Roles {
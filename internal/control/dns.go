@@ -0,0 +1,316 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DNSProviderNone       = "none"
+	DNSProviderHetzner    = "hetzner"
+	DNSProviderRFC2136    = "rfc2136"
+	DNSProviderCloudflare = "cloudflare"
+
+	dnsRecordComment = "Managed by mnbcontrol"
+)
+
+// DNSRecord is a record set, the name is relative to the zone and the values are in zone file presentation format.
+type DNSRecord struct {
+	Name   string
+	Type   string
+	TTL    int
+	Values []string
+}
+
+// DNSRecordRef identifies a record created for a service.
+type DNSRecordRef struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// DNSConfig holds the additional records of a service, names are relative to the zone.
+// Internal adds a record pointing at the private ip of the server.
+type DNSConfig struct {
+	TTL       int         `json:"ttl,omitempty"`
+	Hostnames []string    `json:"hostnames,omitempty"`
+	CNAMEs    []string    `json:"cnames,omitempty"`
+	SRV       []SRVRecord `json:"srv,omitempty"`
+	Internal  bool        `json:"internal,omitempty"`
+}
+
+type SRVRecord struct {
+	Service  string `json:"service"`
+	Protocol string `json:"protocol"`
+	Name     string `json:"name,omitempty"`
+	Port     int    `json:"port"`
+	Priority int    `json:"priority,omitempty"`
+	Weight   int    `json:"weight,omitempty"`
+}
+
+// DNSProvider manages records of a single zone.
+type DNSProvider interface {
+	// SetRecord creates the record set or replaces its values if it exists.
+	SetRecord(ctx context.Context, record DNSRecord) error
+	// DeleteRecord deletes the record set, it must not fail if the record set does not exist.
+	DeleteRecord(ctx context.Context, name, recordType string) error
+}
+
+func newDNSProvider(config *Config, hclient *hcloud.Client) (DNSProvider, error) {
+	switch config.DNSProvider {
+	case "", DNSProviderNone:
+		return noopDNSProvider{}, nil
+	case DNSProviderHetzner:
+		// a zero zone id has always meant disabled dns support
+		if config.DNSZoneID == 0 {
+			return noopDNSProvider{}, nil
+		}
+		return &hetznerDNSProvider{hclient: hclient, zone: &hcloud.Zone{ID: config.DNSZoneID}}, nil
+	case DNSProviderRFC2136:
+		if config.RFC2136Server == "" {
+			return nil, fmt.Errorf("rfc2136 server must be set")
+		}
+		return &rfc2136DNSProvider{
+			server:        config.RFC2136Server,
+			zone:          config.DNSZoneName,
+			tsigKeyName:   config.RFC2136TSIGKeyName,
+			tsigSecret:    os.Getenv("RFC2136_TSIG_SECRET"),
+			tsigAlgorithm: config.RFC2136TSIGAlgorithm,
+		}, nil
+	case DNSProviderCloudflare:
+		token, ok := os.LookupEnv("CLOUDFLARE_API_TOKEN")
+		if !ok {
+			return nil, fmt.Errorf("CLOUDFLARE_API_TOKEN must be set")
+		}
+		if config.CloudflareZoneID == "" {
+			return nil, fmt.Errorf("cloudflare zone id must be set")
+		}
+		return &cloudflareDNSProvider{apiURL: cloudflareAPIURL, token: token, zoneID: config.CloudflareZoneID, zone: config.DNSZoneName}, nil
+	default:
+		return nil, fmt.Errorf("unknown dns provider %s", config.DNSProvider)
+	}
+}
+
+func (control *Control) dnsEnabled() bool {
+	_, noop := control.dns.(noopDNSProvider)
+	return !noop
+}
+
+// serviceDNSName returns the record name of the server relative to the zone.
+func (control *Control) serviceDNSName(serverName string) string {
+	return serverName + control.Config.DNSRecordSuffix
+}
+
+func (control *Control) serviceFQDN(serverName string) string {
+	return control.serviceDNSName(serverName) + "." + control.Config.DNSZoneName
+}
+
+// serviceDNSTTL returns the ttl of the records of the service, the configured dns ttl of the service overrides the default.
+func (control *Control) serviceDNSTTL(serverName string) int {
+	dnsConfig := control.serviceConfig(serverName).DNS
+	if dnsConfig != nil && dnsConfig.TTL > 0 {
+		return dnsConfig.TTL
+	}
+
+	return control.Config.DNSRecordTTL
+}
+
+// serviceDNSRecords returns all records of the service, the values are only filled if the addresses are given.
+func (control *Control) serviceDNSRecords(serverName, ipv4, ipv6 string) []DNSRecord {
+	dnsConfig := control.serviceConfig(serverName).DNS
+	if dnsConfig == nil {
+		dnsConfig = &DNSConfig{}
+	}
+
+	ttl := control.serviceDNSTTL(serverName)
+
+	target := dns.Fqdn(control.serviceFQDN(serverName))
+
+	var records []DNSRecord
+
+	for _, name := range append([]string{control.serviceDNSName(serverName)}, dnsConfig.Hostnames...) {
+		records = append(records,
+			DNSRecord{Name: name, Type: string(hcloud.ZoneRRSetTypeA), TTL: ttl, Values: []string{ipv4}},
+			DNSRecord{Name: name, Type: string(hcloud.ZoneRRSetTypeAAAA), TTL: ttl, Values: []string{ipv6}},
+		)
+	}
+
+	for _, name := range dnsConfig.CNAMEs {
+		records = append(records, DNSRecord{Name: name, Type: string(hcloud.ZoneRRSetTypeCNAME), TTL: ttl, Values: []string{target}})
+	}
+
+	for _, srv := range dnsConfig.SRV {
+		name := srv.Name
+		if name == "" {
+			name = control.serviceDNSName(serverName)
+		}
+
+		records = append(records, DNSRecord{
+			Name:   fmt.Sprintf("_%s._%s.%s", srv.Service, strings.ToLower(srv.Protocol), name),
+			Type:   string(hcloud.ZoneRRSetTypeSRV),
+			TTL:    ttl,
+			Values: []string{fmt.Sprintf("%d %d %d %s", srv.Priority, srv.Weight, srv.Port, target)},
+		})
+	}
+
+	return records
+}
+
+// rememberDNSRecords adds the records to the records created for the service, before they are created,
+// so a failed creation is cleaned up as well.
+func (control *Control) rememberDNSRecords(serviceName string, records []DNSRecord) error {
+	err := control.state.update(func(state *persistentState) {
+		if state.DNSRecords == nil {
+			state.DNSRecords = make(map[string][]DNSRecordRef)
+		}
+
+		for _, record := range records {
+			ref := DNSRecordRef{Name: record.Name, Type: record.Type}
+			if !slices.Contains(state.DNSRecords[serviceName], ref) {
+				state.DNSRecords[serviceName] = append(state.DNSRecords[serviceName], ref)
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save dns records of server %s: %s", serviceName, err)
+	}
+
+	return nil
+}
+
+// createdDNSRecords returns the records created for the service, for servers started before the records were
+// remembered they are derived from the config.
+func (control *Control) createdDNSRecords(serviceName string) []DNSRecordRef {
+	var refs []DNSRecordRef
+	var ok bool
+
+	control.state.view(func(state *persistentState) {
+		refs, ok = state.DNSRecords[serviceName]
+		refs = slices.Clone(refs)
+	})
+
+	if ok {
+		return refs
+	}
+
+	for _, record := range control.serviceDNSRecords(serviceName, "", "") {
+		refs = append(refs, DNSRecordRef{Name: record.Name, Type: record.Type})
+	}
+
+	if dnsConfig := control.serviceConfig(serviceName).DNS; dnsConfig != nil && dnsConfig.Internal {
+		refs = append(refs, DNSRecordRef{Name: control.serviceInternalDNSName(serviceName), Type: string(hcloud.ZoneRRSetTypeA)})
+	}
+
+	return refs
+}
+
+// detachDNSRecordsFromServer deletes exactly the records created for the server.
+func (control *Control) detachDNSRecordsFromServer(ctx context.Context, serverName string) error {
+	for _, ref := range control.createdDNSRecords(serverName) {
+		err := control.dns.DeleteRecord(ctx, ref.Name, ref.Type)
+		if err != nil {
+			return fmt.Errorf("failed to delete dns %s record %s for server %s: %s", ref.Type, ref.Name, serverName, err)
+		}
+	}
+
+	err := control.state.update(func(state *persistentState) {
+		delete(state.DNSRecords, serverName)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save dns records of server %s: %s", serverName, err)
+	}
+
+	return nil
+}
+
+type noopDNSProvider struct{}
+
+func (noopDNSProvider) SetRecord(context.Context, DNSRecord) error {
+	return nil
+}
+
+func (noopDNSProvider) DeleteRecord(context.Context, string, string) error {
+	return nil
+}
+
+type hetznerDNSProvider struct {
+	hclient *hcloud.Client
+	zone    *hcloud.Zone
+}
+
+func (provider *hetznerDNSProvider) SetRecord(ctx context.Context, record DNSRecord) error {
+	records := make([]hcloud.ZoneRRSetRecord, 0, len(record.Values))
+
+	for _, value := range record.Values {
+		records = append(records, hcloud.ZoneRRSetRecord{
+			Value:   value,
+			Comment: dnsRecordComment,
+		})
+	}
+
+	rrset, _, err := provider.hclient.Zone.GetRRSetByNameAndType(ctx, provider.zone, record.Name, hcloud.ZoneRRSetType(record.Type))
+	if err != nil {
+		return err
+	}
+
+	if rrset == nil {
+		result, _, err := provider.hclient.Zone.CreateRRSet(ctx, provider.zone, hcloud.ZoneRRSetCreateOpts{
+			Name:    record.Name,
+			Type:    hcloud.ZoneRRSetType(record.Type),
+			TTL:     new(record.TTL),
+			Records: records,
+		})
+		if err != nil {
+			return err
+		}
+
+		return provider.hclient.Action.WaitFor(ctx, result.Action)
+	}
+
+	log.Infof("dns record %s %s exists already, replacing its records", record.Name, record.Type)
+
+	rrset.Zone = provider.zone
+
+	action, _, err := provider.hclient.Zone.SetRRSetRecords(ctx, rrset, hcloud.ZoneRRSetSetRecordsOpts{Records: records})
+	if err != nil {
+		return err
+	}
+
+	err = provider.hclient.Action.WaitFor(ctx, action)
+	if err != nil {
+		return err
+	}
+
+	if rrset.TTL != nil && *rrset.TTL == record.TTL {
+		return nil
+	}
+
+	action, _, err = provider.hclient.Zone.ChangeRRSetTTL(ctx, rrset, hcloud.ZoneRRSetChangeTTLOpts{TTL: new(record.TTL)})
+	if err != nil {
+		return err
+	}
+
+	return provider.hclient.Action.WaitFor(ctx, action)
+}
+
+func (provider *hetznerDNSProvider) DeleteRecord(ctx context.Context, name, recordType string) error {
+	result, _, err := provider.hclient.Zone.DeleteRRSet(ctx, &hcloud.ZoneRRSet{
+		Zone: provider.zone,
+		Name: name,
+		Type: hcloud.ZoneRRSetType(recordType),
+	})
+	if hcloud.IsError(err, hcloud.ErrorCodeNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return provider.hclient.Action.WaitFor(ctx, result.Action)
+}
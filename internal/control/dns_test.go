@@ -0,0 +1,79 @@
+package control
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// testDNSProvider records the deleted record sets as "name type".
+type testDNSProvider struct {
+	deleted []string
+}
+
+func (provider *testDNSProvider) SetRecord(context.Context, DNSRecord) error {
+	return nil
+}
+
+func (provider *testDNSProvider) DeleteRecord(_ context.Context, name, recordType string) error {
+	provider.deleted = append(provider.deleted, name+" "+recordType)
+	return nil
+}
+
+func TestDetachDNSRecordsFromServer(t *testing.T) {
+	tests := []struct {
+		name       string
+		remembered []DNSRecord
+		expected   []string
+	}{
+		{
+			// the hostname was removed from the config while the server was running
+			name: "remembered records",
+			remembered: []DNSRecord{
+				{Name: "minecraft", Type: "AAAA"},
+				{Name: "mc", Type: "AAAA"},
+				{Name: "mc", Type: "AAAA"},
+			},
+			expected: []string{"mc AAAA", "minecraft AAAA"},
+		},
+		{
+			// servers started before the records were remembered
+			name:     "config",
+			expected: []string{"minecraft A", "minecraft AAAA", "play CNAME"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &testDNSProvider{}
+			control := &Control{
+				Config: &Config{Services: map[string]*ServiceConfig{
+					"minecraft": {DNS: &DNSConfig{CNAMEs: []string{"play"}}},
+				}},
+				dns:   provider,
+				state: &stateStore{},
+			}
+
+			if tt.remembered != nil {
+				err := control.rememberDNSRecords("minecraft", tt.remembered)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+			}
+
+			err := control.detachDNSRecordsFromServer(context.Background(), "minecraft")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			slices.Sort(provider.deleted)
+			if !slices.Equal(provider.deleted, tt.expected) {
+				t.Errorf("expected %q to be deleted, got %q", tt.expected, provider.deleted)
+			}
+
+			if _, ok := control.state.state.DNSRecords["minecraft"]; ok {
+				t.Error("expected the records to be forgotten")
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	eventCheckInterval = time.Minute
+	// events without an end time are expected to run this long
+	defaultEventDuration = 12 * time.Hour
+)
+
+// eventServiceRegexp matches the service reference in the description or location of a scheduled event.
+var eventServiceRegexp = regexp.MustCompile(`(?i)\bservice:\s*([a-z0-9][a-z0-9-]*)`)
+
+// eventService returns the service referenced by the scheduled event or an empty string.
+func eventService(event *discordgo.GuildScheduledEvent) string {
+	for _, text := range []string{event.Description, event.EntityMetadata.Location} {
+		match := eventServiceRegexp.FindStringSubmatch(text)
+		if match != nil {
+			return strings.ToLower(match[1])
+		}
+	}
+
+	return ""
+}
+
+// eventEnd returns the scheduled end of the event.
+func eventEnd(event *discordgo.GuildScheduledEvent) time.Time {
+	if event.ScheduledEndTime != nil {
+		return *event.ScheduledEndTime
+	}
+
+	return event.ScheduledStartTime.Add(defaultEventDuration)
+}
+
+// checkScheduledEvents starts the services of the guild events which begin within the lead time,
+// every event is handled once unless its start failed and is forgotten after it ended or was cancelled.
+func (control *Control) checkScheduledEvents(ctx context.Context) {
+	events, err := control.discordSession.GuildScheduledEvents(control.Config.DiscordGuildID, false)
+	if err != nil {
+		log.Errorf("event error: failed to list scheduled events: %s", err)
+		return
+	}
+
+	now := time.Now()
+	upcoming := make(map[string]bool)
+
+	var due []*discordgo.GuildScheduledEvent
+
+	for _, event := range events {
+		if event.Status != discordgo.GuildScheduledEventStatusScheduled && event.Status != discordgo.GuildScheduledEventStatusActive {
+			continue
+		}
+
+		if eventService(event) == "" || !now.Before(eventEnd(event)) {
+			continue
+		}
+
+		upcoming[event.ID] = true
+
+		if now.Before(event.ScheduledStartTime.Add(-control.Config.EventLeadTime)) {
+			continue
+		}
+
+		due = append(due, event)
+	}
+
+	var started []*discordgo.GuildScheduledEvent
+
+	err = control.state.update(func(state *persistentState) {
+		if state.EventStarts == nil {
+			state.EventStarts = make(map[string]string)
+		}
+
+		for eventID := range state.EventStarts {
+			if !upcoming[eventID] {
+				delete(state.EventStarts, eventID)
+			}
+		}
+
+		for _, event := range due {
+			if _, ok := state.EventStarts[event.ID]; ok {
+				continue
+			}
+
+			state.EventStarts[event.ID] = eventService(event)
+			started = append(started, event)
+		}
+	})
+	if err != nil {
+		log.Errorf("event error: failed to save event starts: %s", err)
+		return
+	}
+
+	for _, event := range started {
+		go control.startEventServer(ctx, event)
+	}
+}
+
+// forgetEventStart removes the event from the handled events, so the next check starts its service again.
+func (control *Control) forgetEventStart(eventID string) {
+	err := control.state.update(func(state *persistentState) {
+		delete(state.EventStarts, eventID)
+	})
+	if err != nil {
+		log.Errorf("event error: failed to save event starts: %s", err)
+	}
+}
+
+// startEventServer starts the service of the event on behalf of its creator or extends the running server,
+// so it runs until the grace period after the event end, and posts the connection info into the event channel.
+func (control *Control) startEventServer(ctx context.Context, event *discordgo.GuildScheduledEvent) {
+	serviceName := eventService(event)
+	until := eventEnd(event).Add(control.Config.EventGracePeriod)
+
+	log.Infof("event: starting server %s for event %s until %s", serviceName, event.Name, until.Format(time.RFC3339))
+
+	channelID := event.ChannelID
+	if channelID == "" {
+		channelID = control.Config.DiscordChannelID
+	}
+
+	announce := func(msg string) {
+		_, err := control.discordSession.ChannelMessageSend(channelID, msg)
+		if err != nil {
+			log.Errorf("discord: failed to announce server %s for event %s: %s", serviceName, event.Name, err)
+		}
+	}
+
+	member, err := control.guildMember(event.CreatorID)
+	if err != nil {
+		log.Errorf("event error: %s", err)
+		control.forgetEventStart(event.ID)
+		return
+	}
+
+	if member == nil ||
+		!memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID, control.Config.DiscordUserRoleID) ||
+		!control.memberMayAccess(member, serviceName, ActionStart) {
+		log.Infof("event: creator %s of event %s may not start server %s", event.CreatorID, event.Name, serviceName)
+		announce(fmt.Sprintf("Server %s can not be started for event %s, its creator may not start it.", serviceName, event.Name))
+		return
+	}
+
+	server, err := control.ensureEventServer(ctx, event.CreatorID, serviceName, until)
+
+	var queuedErr *QueuedError
+
+	switch {
+	case errors.As(err, &queuedErr):
+		announce(fmt.Sprintf("Event %s: %s.", event.Name, queuedErr))
+	case errors.Is(err, ErrBudgetExceeded) || errors.Is(err, ErrQuotaExceeded) ||
+		errors.Is(err, ErrNotOwner) || errors.Is(err, ErrNotAllowed):
+		announce(fmt.Sprintf("Server %s could not be started for event %s: %s", serviceName, event.Name, err))
+	case err != nil:
+		// the next check retries the start
+		log.Errorf("event error: failed to start server %s for event %s, retrying: %s", serviceName, event.Name, err)
+		control.forgetEventStart(event.ID)
+	default:
+		control.announceWhenReady(server, announce, fmt.Sprintf(
+			"Server %s for event %s is ready.\nDNS: %s\nIPv4: %s\nIPv6: %s\nIt will run until %s",
+			server.Name,
+			event.Name,
+			serverDNSPtr(server),
+			valueOrNA(serverIPv4(server)),
+			valueOrNA(serverIPv6(server)),
+			until.Format(time.RFC3339),
+		))
+	}
+}
+
+// ensureEventServer starts the server with a ttl reaching until or extends its ttl if it is already running.
+func (control *Control) ensureEventServer(ctx context.Context, actor, serviceName string, until time.Time) (*hcloud.Server, error) {
+	server, _, err := control.hclient.Server.Get(ctx, serviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server %s by name: %s", serviceName, err)
+	}
+
+	if server == nil {
+		ttl := time.Until(until).Round(time.Minute)
+
+		member, err := control.guildMember(actor)
+		if err != nil {
+			return nil, err
+		}
+
+		if maxTTL := control.memberLimits(member).maxTTL; ttl > maxTTL {
+			return nil, fmt.Errorf("%w: the event runs longer than the maximum ttl of %s", ErrQuotaExceeded, maxTTL)
+		}
+
+		return control.startServer(ctx, actor, StartServerRequest{
+			ServerName: serviceName,
+			TTL:        ttl.String(),
+			Confirm:    true,
+		})
+	}
+
+	ttl, err := serverTTL(server)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl.Before(until) {
+		_, err = control.extendServer(ctx, actor, ExtendServerRequest{
+			ServerName: serviceName,
+			TTL:        until.Sub(ttl).Round(time.Minute).String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return server, nil
+}
@@ -0,0 +1,61 @@
+package control
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestEventService(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		location    string
+		expected    string
+	}{
+		{name: "description", description: "Raid night\nservice: valheim", expected: "valheim"},
+		{name: "location", location: "Service:Minecraft", expected: "minecraft"},
+		{name: "description first", description: "service: valheim", location: "service: minecraft", expected: "valheim"},
+		{name: "hyphen", description: "service: space-engineers", expected: "space-engineers"},
+		{name: "no reference", description: "we play some games", location: "voice channel"},
+		{name: "part of a word", description: "selfservice: minecraft"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := &discordgo.GuildScheduledEvent{
+				Description:    tt.description,
+				EntityMetadata: discordgo.GuildScheduledEventEntityMetadata{Location: tt.location},
+			}
+
+			if service := eventService(event); service != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, service)
+			}
+		})
+	}
+}
+
+func TestEventEnd(t *testing.T) {
+	start := time.Date(2026, time.January, 9, 19, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Hour)
+
+	tests := []struct {
+		name     string
+		end      *time.Time
+		expected time.Time
+	}{
+		{name: "scheduled end", end: &end, expected: end},
+		{name: "no end", expected: start.Add(defaultEventDuration)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := &discordgo.GuildScheduledEvent{ScheduledStartTime: start, ScheduledEndTime: tt.end}
+
+			if eventEnd := eventEnd(event); !eventEnd.Equal(tt.expected) {
+				t.Errorf("expected %s, got %s", tt.expected, eventEnd)
+			}
+		})
+	}
+}
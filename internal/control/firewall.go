@@ -0,0 +1,259 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	defaultSourceIPs = []string{"0.0.0.0/0", "::/0"}
+)
+
+// PortConfig opens a port or port range like 27015-27020 of the service, icmp rules have no port.
+// Restricted ports are only open to their source ips and to ips allowed temporarily.
+type PortConfig struct {
+	Port        string   `json:"port,omitempty"`
+	Protocol    string   `json:"protocol"`
+	SourceIPs   []string `json:"sourceIPs,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Restricted  bool     `json:"restricted,omitempty"`
+}
+
+// firewallLocks serializes the updates of the rules per service firewall, as rules can only be set as a whole.
+type firewallLocks struct {
+	mutex sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func newFirewallLocks() *firewallLocks {
+	return &firewallLocks{locks: make(map[string]*sync.Mutex)}
+}
+
+// lock locks the firewall of the service and returns the function to unlock it.
+func (firewallLocks *firewallLocks) lock(serviceName string) func() {
+	firewallLocks.mutex.Lock()
+	lock, ok := firewallLocks.locks[serviceName]
+	if !ok {
+		lock = &sync.Mutex{}
+		firewallLocks.locks[serviceName] = lock
+	}
+	firewallLocks.mutex.Unlock()
+
+	lock.Lock()
+
+	return lock.Unlock
+}
+
+func (control *Control) firewallName(serviceName string) string {
+	return "mnbcontrol-" + serviceName
+}
+
+// firewallRules returns the rules of the service firewall derived from its port definitions.
+func (control *Control) firewallRules(serviceName string) ([]hcloud.FirewallRule, error) {
+	var rules []hcloud.FirewallRule
+
+	for _, port := range control.serviceConfig(serviceName).Ports {
+		sourceIPs := port.SourceIPs
+		if len(sourceIPs) == 0 {
+			if port.Restricted {
+				continue
+			}
+			sourceIPs = defaultSourceIPs
+		}
+
+		rule := hcloud.FirewallRule{
+			Direction: hcloud.FirewallRuleDirectionIn,
+			Protocol:  hcloud.FirewallRuleProtocol(strings.ToLower(port.Protocol)),
+		}
+
+		for _, sourceIP := range sourceIPs {
+			_, sourceNet, err := net.ParseCIDR(sourceIP)
+			if err != nil {
+				return nil, fmt.Errorf("invalid source ip %s for service %s: %s", sourceIP, serviceName, err)
+			}
+			rule.SourceIPs = append(rule.SourceIPs, *sourceNet)
+		}
+
+		if port.Port != "" {
+			rule.Port = new(port.Port)
+		}
+
+		if port.Description != "" {
+			rule.Description = new(port.Description)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func (control *Control) getFirewall(ctx context.Context, serviceName string) (*hcloud.Firewall, error) {
+	firewall, _, err := control.hclient.Firewall.GetByName(ctx, control.firewallName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get firewall of service %s: %s", serviceName, err)
+	}
+
+	if firewall != nil && firewall.Labels[LabelManagedBy] != LabelValueMangedByControl {
+		return nil, fmt.Errorf("firewall %s is not managed by mnbcontrol", firewall.Name)
+	}
+
+	return firewall, nil
+}
+
+// ensureFirewall creates the firewall of the service or updates its rules if the port definitions changed,
+// it returns nil if the service has no port definitions and deletes the firewall left from earlier ones.
+func (control *Control) ensureFirewall(ctx context.Context, serviceName string) (*hcloud.Firewall, error) {
+	if len(control.serviceConfig(serviceName).Ports) == 0 {
+		return nil, control.deleteFirewall(ctx, serviceName)
+	}
+
+	unlock := control.firewallLocks.lock(serviceName)
+	defer unlock()
+
+	rules, err := control.firewallRules(serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	firewall, err := control.getFirewall(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	if firewall == nil {
+		result, _, err := control.hclient.Firewall.Create(ctx, hcloud.FirewallCreateOpts{
+			Name: control.firewallName(serviceName),
+			Labels: map[string]string{
+				LabelManagedBy: LabelValueMangedByControl,
+				LabelService:   serviceName,
+			},
+			Rules: rules,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create firewall for service %s: %s", serviceName, err)
+		}
+
+		err = control.hclient.Action.WaitFor(ctx, result.Actions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create firewall for service %s: %s", serviceName, err)
+		}
+
+		log.Infof("created firewall %s for service %s", result.Firewall.Name, serviceName)
+
+		return result.Firewall, nil
+	}
+
+	// temporary allow rules are kept until the daemon removes them
+	rules = append(rules, filterFirewallRules(firewall.Rules, func(rule hcloud.FirewallRule) bool {
+		return isAllowRule(rule) && !isExpiredAllowRule(rule)
+	})...)
+
+	if firewallRulesEqual(firewall.Rules, rules) {
+		return firewall, nil
+	}
+
+	actions, _, err := control.hclient.Firewall.SetRules(ctx, firewall, hcloud.FirewallSetRulesOpts{Rules: rules})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update firewall rules of service %s: %s", serviceName, err)
+	}
+
+	err = control.hclient.Action.WaitFor(ctx, actions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update firewall rules of service %s: %s", serviceName, err)
+	}
+
+	log.Infof("updated firewall rules of service %s", serviceName)
+
+	return firewall, nil
+}
+
+// serviceFirewalls returns the firewalls to attach when creating a server of the service.
+func (control *Control) serviceFirewalls(ctx context.Context, serviceName string) ([]*hcloud.ServerCreateFirewall, error) {
+	firewall, err := control.ensureFirewall(ctx, serviceName)
+	if err != nil || firewall == nil {
+		return nil, err
+	}
+
+	return []*hcloud.ServerCreateFirewall{{Firewall: *firewall}}, nil
+}
+
+// syncFirewalls updates the firewalls of all configured services, as port definitions only change on restart.
+func (control *Control) syncFirewalls(ctx context.Context) {
+	for serviceName := range control.Config.Services {
+		_, err := control.ensureFirewall(ctx, serviceName)
+		if err != nil {
+			log.Errorf("firewall error: %s", err)
+		}
+	}
+}
+
+// deleteFirewall detaches the firewall of the service from its servers and deletes it.
+func (control *Control) deleteFirewall(ctx context.Context, serviceName string) error {
+	unlock := control.firewallLocks.lock(serviceName)
+	defer unlock()
+
+	firewall, err := control.getFirewall(ctx, serviceName)
+	if err != nil || firewall == nil {
+		return err
+	}
+
+	// a firewall can not be deleted while it is applied to a server
+	if len(firewall.AppliedTo) > 0 {
+		actions, _, err := control.hclient.Firewall.RemoveResources(ctx, firewall, firewall.AppliedTo)
+		if err != nil {
+			return fmt.Errorf("failed to detach firewall of service %s: %s", serviceName, err)
+		}
+
+		err = control.hclient.Action.WaitFor(ctx, actions...)
+		if err != nil {
+			return fmt.Errorf("failed to detach firewall of service %s: %s", serviceName, err)
+		}
+	}
+
+	_, err = control.hclient.Firewall.Delete(ctx, firewall)
+	if err != nil {
+		return fmt.Errorf("failed to delete firewall of service %s: %s", serviceName, err)
+	}
+
+	log.Infof("deleted firewall %s of service %s", firewall.Name, serviceName)
+
+	return nil
+}
+
+func firewallRulesEqual(a, b []hcloud.FirewallRule) bool {
+	return slices.Equal(firewallRuleKeys(a), firewallRuleKeys(b))
+}
+
+func firewallRuleKeys(rules []hcloud.FirewallRule) []string {
+	keys := make([]string, 0, len(rules))
+
+	for _, rule := range rules {
+		var sourceIPs []string
+		for _, sourceIP := range rule.SourceIPs {
+			sourceIPs = append(sourceIPs, sourceIP.String())
+		}
+		slices.Sort(sourceIPs)
+
+		var port, description string
+		if rule.Port != nil {
+			port = *rule.Port
+		}
+		if rule.Description != nil {
+			description = *rule.Description
+		}
+
+		keys = append(keys, fmt.Sprintf("%s/%s/%s/%s/%s", rule.Direction, rule.Protocol, port, strings.Join(sourceIPs, ","), description))
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
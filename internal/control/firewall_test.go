@@ -0,0 +1,146 @@
+package control
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func testFirewallRule(protocol, port string, sourceIPs ...string) hcloud.FirewallRule {
+	rule := hcloud.FirewallRule{
+		Direction: hcloud.FirewallRuleDirectionIn,
+		Protocol:  hcloud.FirewallRuleProtocol(protocol),
+	}
+
+	if port != "" {
+		rule.Port = new(port)
+	}
+
+	for _, sourceIP := range sourceIPs {
+		_, sourceNet, _ := net.ParseCIDR(sourceIP)
+		rule.SourceIPs = append(rule.SourceIPs, *sourceNet)
+	}
+
+	return rule
+}
+
+func TestFirewallRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		ports    []PortConfig
+		expected []hcloud.FirewallRule
+		err      bool
+	}{
+		{
+			name:     "open to everyone",
+			ports:    []PortConfig{{Port: "25565", Protocol: "TCP"}},
+			expected: []hcloud.FirewallRule{testFirewallRule("tcp", "25565", defaultSourceIPs...)},
+		},
+		{
+			name:     "source ips",
+			ports:    []PortConfig{{Port: "27015-27020", Protocol: "udp", SourceIPs: []string{"192.0.2.0/24"}}},
+			expected: []hcloud.FirewallRule{testFirewallRule("udp", "27015-27020", "192.0.2.0/24")},
+		},
+		{
+			name:     "icmp",
+			ports:    []PortConfig{{Protocol: "icmp"}},
+			expected: []hcloud.FirewallRule{testFirewallRule("icmp", "", defaultSourceIPs...)},
+		},
+		{
+			// restricted ports without source ips are only opened by allow rules
+			name:  "restricted",
+			ports: []PortConfig{{Port: "22", Protocol: "tcp", Restricted: true}},
+		},
+		{
+			name:     "restricted with source ips",
+			ports:    []PortConfig{{Port: "22", Protocol: "tcp", Restricted: true, SourceIPs: []string{"198.51.100.7/32"}}},
+			expected: []hcloud.FirewallRule{testFirewallRule("tcp", "22", "198.51.100.7/32")},
+		},
+		{
+			name:  "invalid source ip",
+			ports: []PortConfig{{Port: "22", Protocol: "tcp", SourceIPs: []string{"198.51.100.7"}}},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			control := &Control{Config: &Config{Services: map[string]*ServiceConfig{"minecraft": {Ports: tt.ports}}}}
+
+			rules, err := control.firewallRules("minecraft")
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !firewallRulesEqual(rules, tt.expected) {
+				t.Errorf("expected %v, got %v", firewallRuleKeys(tt.expected), firewallRuleKeys(rules))
+			}
+		})
+	}
+}
+
+func TestFirewallRulesEqual(t *testing.T) {
+	described := testFirewallRule("tcp", "25565", "0.0.0.0/0")
+	described.Description = new("minecraft")
+
+	tests := []struct {
+		name  string
+		a, b  []hcloud.FirewallRule
+		equal bool
+	}{
+		{name: "empty", equal: true},
+		{
+			name:  "same",
+			a:     []hcloud.FirewallRule{testFirewallRule("tcp", "25565", "0.0.0.0/0")},
+			b:     []hcloud.FirewallRule{testFirewallRule("tcp", "25565", "0.0.0.0/0")},
+			equal: true,
+		},
+		{
+			name:  "rule order",
+			a:     []hcloud.FirewallRule{testFirewallRule("tcp", "22", "0.0.0.0/0"), testFirewallRule("udp", "53", "0.0.0.0/0")},
+			b:     []hcloud.FirewallRule{testFirewallRule("udp", "53", "0.0.0.0/0"), testFirewallRule("tcp", "22", "0.0.0.0/0")},
+			equal: true,
+		},
+		{
+			name:  "source ip order",
+			a:     []hcloud.FirewallRule{testFirewallRule("tcp", "22", "0.0.0.0/0", "::/0")},
+			b:     []hcloud.FirewallRule{testFirewallRule("tcp", "22", "::/0", "0.0.0.0/0")},
+			equal: true,
+		},
+		{
+			name: "port changed",
+			a:    []hcloud.FirewallRule{testFirewallRule("tcp", "22", "0.0.0.0/0")},
+			b:    []hcloud.FirewallRule{testFirewallRule("tcp", "2222", "0.0.0.0/0")},
+		},
+		{
+			name: "source ip added",
+			a:    []hcloud.FirewallRule{testFirewallRule("tcp", "22", "0.0.0.0/0")},
+			b:    []hcloud.FirewallRule{testFirewallRule("tcp", "22", "0.0.0.0/0", "::/0")},
+		},
+		{
+			name: "description changed",
+			a:    []hcloud.FirewallRule{testFirewallRule("tcp", "25565", "0.0.0.0/0")},
+			b:    []hcloud.FirewallRule{described},
+		},
+		{
+			name: "rule removed",
+			a:    []hcloud.FirewallRule{testFirewallRule("tcp", "22", "0.0.0.0/0"), testFirewallRule("udp", "53", "0.0.0.0/0")},
+			b:    []hcloud.FirewallRule{testFirewallRule("tcp", "22", "0.0.0.0/0")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if equal := firewallRulesEqual(tt.a, tt.b); equal != tt.equal {
+				t.Errorf("expected %t, got %t", tt.equal, equal)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultHealthFailureThreshold = 3
+	defaultHealthRebootCooldown   = 30 * time.Minute
+	healthHistoryLength           = 20
+)
+
+type HealthConfig struct {
+	Probe            *ProbeConfig `json:"probe,omitempty"`
+	FailureThreshold int          `json:"failureThreshold,omitempty"`
+	AutoReboot       bool         `json:"autoReboot,omitempty"`
+	RebootCooldown   string       `json:"rebootCooldown,omitempty"`
+}
+
+type HealthRecord struct {
+	Time    time.Time `json:"time"`
+	Healthy bool      `json:"healthy"`
+	Error   string    `json:"error,omitempty"`
+}
+
+type HealthStatus struct {
+	ConsecutiveFailures int            `json:"consecutiveFailures"`
+	LastReboot          *time.Time     `json:"lastReboot,omitempty"`
+	History             []HealthRecord `json:"history"`
+
+	alerted bool
+}
+
+type healthMonitor struct {
+	mutex    sync.Mutex
+	statuses map[string]*HealthStatus
+	// checking holds the servers with a check in progress, it is kept apart from the statuses
+	// so removing a status does not allow a second check
+	checking map[string]bool
+}
+
+func newHealthMonitor() *healthMonitor {
+	return &healthMonitor{statuses: make(map[string]*HealthStatus), checking: make(map[string]bool)}
+}
+
+// status returns a copy of the health status of the server, it is nil if the server was never checked.
+func (monitor *healthMonitor) status(serverName string) *HealthStatus {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	status, ok := monitor.statuses[serverName]
+	if !ok {
+		return nil
+	}
+
+	statusCopy := *status
+	statusCopy.History = append([]HealthRecord(nil), status.History...)
+
+	return &statusCopy
+}
+
+// reset clears the failure state of the server but keeps its history.
+func (monitor *healthMonitor) reset(serverName string) {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	if status, ok := monitor.statuses[serverName]; ok {
+		status.ConsecutiveFailures = 0
+		status.alerted = false
+	}
+}
+
+// remove forgets the health status of the server.
+func (monitor *healthMonitor) remove(serverName string) {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	delete(monitor.statuses, serverName)
+}
+
+// prune forgets the health statuses of the servers which do not exist anymore.
+func (monitor *healthMonitor) prune(existing map[string]bool) {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	for serverName := range monitor.statuses {
+		if !existing[serverName] {
+			delete(monitor.statuses, serverName)
+		}
+	}
+}
+
+// beginCheck marks a check of the server as in progress, it returns false if one is already running.
+func (monitor *healthMonitor) beginCheck(serverName string) bool {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	if monitor.checking[serverName] {
+		return false
+	}
+
+	monitor.checking[serverName] = true
+
+	return true
+}
+
+func (monitor *healthMonitor) endCheck(serverName string) {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	delete(monitor.checking, serverName)
+}
+
+func (control *Control) checkHealthOfServers(ctx context.Context) {
+	managedServers, err := control.listServers(ctx)
+	if err != nil {
+		log.Errorf("health error: %s", err)
+		return
+	}
+
+	existing := make(map[string]bool)
+
+	for _, server := range managedServers {
+		existing[server.Name] = true
+
+		svc := control.serviceConfig(server.Name)
+		if svc.Health == nil || server.Status != hcloud.ServerStatusRunning {
+			continue
+		}
+
+		// give servers time to come up before they count as unhealthy
+		if server.Labels[LabelReady] != "true" && time.Since(server.Created) < defaultReadinessTimeout {
+			continue
+		}
+
+		// a slow probe or reboot must not overlap with the next check
+		if !control.health.beginCheck(server.Name) {
+			log.Debugf("health: check of server %s still in progress", server.Name)
+			continue
+		}
+
+		go func() {
+			defer control.health.endCheck(server.Name)
+
+			control.checkHealth(ctx, server, svc)
+		}()
+	}
+
+	control.health.prune(existing)
+}
+
+func (control *Control) checkHealth(ctx context.Context, server *hcloud.Server, svc *ServiceConfig) {
+	monitor := control.health
+
+	monitor.mutex.Lock()
+	status, ok := monitor.statuses[server.Name]
+	if !ok {
+		status = &HealthStatus{}
+		monitor.statuses[server.Name] = status
+	}
+	monitor.mutex.Unlock()
+
+	probe := svc.Health.Probe
+	if probe == nil {
+		probe = svc.Readiness
+	}
+	if probe == nil {
+		log.Errorf("health error: no probe configured for server %s", server.Name)
+		return
+	}
+
+	err := probe.check(ctx, serverAddress(server))
+
+	record := HealthRecord{Time: time.Now(), Healthy: err == nil}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	threshold := svc.Health.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultHealthFailureThreshold
+	}
+
+	monitor.mutex.Lock()
+	status.History = append(status.History, record)
+	if len(status.History) > healthHistoryLength {
+		status.History = status.History[len(status.History)-healthHistoryLength:]
+	}
+
+	if err == nil {
+		recovered := status.alerted
+		status.ConsecutiveFailures = 0
+		status.alerted = false
+		monitor.mutex.Unlock()
+
+		if recovered {
+			control.notify(fmt.Sprintf("Server %s is healthy again", server.Name))
+		}
+		return
+	}
+
+	status.ConsecutiveFailures++
+	failures := status.ConsecutiveFailures
+	alert := failures >= threshold && !status.alerted
+	if alert {
+		status.alerted = true
+	}
+	monitor.mutex.Unlock()
+
+	log.Infof("health check for server %s failed (%d/%d): %s", server.Name, failures, threshold, err)
+
+	if failures < threshold {
+		return
+	}
+
+	if alert {
+		control.notify(fmt.Sprintf(
+			"Server %s failed %d consecutive health checks, <@&%s> please have a look",
+			server.Name,
+			failures,
+			control.Config.DiscordAdminRoleID,
+		))
+	}
+
+	if svc.Health.AutoReboot {
+		control.autoReboot(ctx, server.Name, svc.Health, status)
+	}
+}
+
+func (control *Control) autoReboot(ctx context.Context, serverName string, healthConfig *HealthConfig, status *HealthStatus) {
+	cooldown := defaultHealthRebootCooldown
+
+	if healthConfig.RebootCooldown != "" {
+		var err error
+		cooldown, err = time.ParseDuration(healthConfig.RebootCooldown)
+		if err != nil {
+			log.Errorf("health error: failed to parse reboot cooldown of server %s: %s", serverName, err)
+			return
+		}
+	}
+
+	now := time.Now()
+
+	control.health.mutex.Lock()
+	if status.LastReboot != nil && now.Sub(*status.LastReboot) < cooldown {
+		control.health.mutex.Unlock()
+		log.Infof("health: skipping reboot of server %s, still in cooldown", serverName)
+		return
+	}
+	status.LastReboot = &now
+	control.health.mutex.Unlock()
+
+	control.notify(fmt.Sprintf("Server %s is unhealthy and will be rebooted automatically", serverName))
+
+	err := control.rebootServer(ctx, serverName)
+	if err != nil {
+		log.Errorf("health error: failed to reboot server %s: %s", serverName, err)
+		control.notify(fmt.Sprintf("Automatic reboot of server %s failed", serverName))
+		return
+	}
+
+	control.health.reset(serverName)
+}
@@ -0,0 +1,35 @@
+package control
+
+import "testing"
+
+func TestHealthMonitor(t *testing.T) {
+	monitor := newHealthMonitor()
+	monitor.statuses["minecraft"] = &HealthStatus{ConsecutiveFailures: 2}
+	monitor.statuses["valheim"] = &HealthStatus{ConsecutiveFailures: 1}
+
+	if !monitor.beginCheck("minecraft") {
+		t.Fatal("expected the first check to begin")
+	}
+	if monitor.beginCheck("minecraft") {
+		t.Error("expected a second check to wait for the first one")
+	}
+
+	// removing the status keeps the check in progress
+	monitor.remove("minecraft")
+	if monitor.status("minecraft") != nil {
+		t.Error("expected the status to be removed")
+	}
+	if monitor.beginCheck("minecraft") {
+		t.Error("expected the check to be still in progress")
+	}
+
+	monitor.endCheck("minecraft")
+	if !monitor.beginCheck("minecraft") {
+		t.Error("expected a check to begin after the previous one ended")
+	}
+
+	monitor.prune(map[string]bool{"minecraft": true})
+	if monitor.status("valheim") != nil {
+		t.Error("expected the status of the missing server to be pruned")
+	}
+}
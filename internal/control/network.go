@@ -0,0 +1,100 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	log "github.com/sirupsen/logrus"
+)
+
+// NetworkConfig attaches servers of the service to a private network, the ip is assigned automatically if empty.
+type NetworkConfig struct {
+	ID int64  `json:"id"`
+	IP string `json:"ip,omitempty"`
+}
+
+// createNetworks returns the networks to attach when creating a server of the service,
+// services with their own networks are attached after creation as fixed ips can not be set on creation.
+func (control *Control) createNetworks(serviceName string) []*hcloud.Network {
+	if len(control.serviceConfig(serviceName).Networks) > 0 {
+		return nil
+	}
+
+	return control.Config.Networks
+}
+
+// startAfterCreate reports whether the server can be started right away or must be attached to its networks first.
+func (control *Control) startAfterCreate(serviceName string) bool {
+	return len(control.serviceConfig(serviceName).Networks) == 0
+}
+
+// attachServiceNetworks attaches the created server to the networks of the service and powers it on.
+func (control *Control) attachServiceNetworks(ctx context.Context, result hcloud.ServerCreateResult) (*hcloud.Server, error) {
+	server := result.Server
+
+	err := control.hclient.Action.WaitFor(ctx, result.Action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server %s: %s", server.Name, err)
+	}
+
+	for _, network := range control.serviceConfig(server.Name).Networks {
+		opts := hcloud.ServerAttachToNetworkOpts{Network: &hcloud.Network{ID: network.ID}}
+
+		if network.IP != "" {
+			opts.IP = net.ParseIP(network.IP)
+			if opts.IP == nil {
+				return nil, fmt.Errorf("invalid private ip %s for server %s", network.IP, server.Name)
+			}
+		}
+
+		action, _, err := control.hclient.Server.AttachToNetwork(ctx, server, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to attach server %s to network %d: %s", server.Name, network.ID, err)
+		}
+
+		err = control.hclient.Action.WaitFor(ctx, action)
+		if err != nil {
+			return nil, fmt.Errorf("failed to attach server %s to network %d: %s", server.Name, network.ID, err)
+		}
+
+		log.Infof("attached server %s to network %d", server.Name, network.ID)
+	}
+
+	action, _, err := control.hclient.Server.Poweron(ctx, server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to power on server %s: %s", server.Name, err)
+	}
+
+	err = control.hclient.Action.WaitFor(ctx, action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to power on server %s: %s", server.Name, err)
+	}
+
+	// the private ips are only known after attaching
+	server, _, err = control.hclient.Server.GetByID(ctx, server.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server %s: %s", result.Server.Name, err)
+	}
+
+	if server == nil {
+		return nil, fmt.Errorf("server %s vanished after creation", result.Server.Name)
+	}
+
+	return server, nil
+}
+
+// serverPrivateIP returns the ip of the server in its first private network.
+func serverPrivateIP(server *hcloud.Server) string {
+	if len(server.PrivateNet) == 0 {
+		return ""
+	}
+
+	return server.PrivateNet[0].IP.String()
+}
+
+// serviceInternalDNSName returns the name of the record pointing at the private ip, relative to the zone.
+func (control *Control) serviceInternalDNSName(serverName string) string {
+	return serverName + control.Config.DNSInternalSuffix
+}
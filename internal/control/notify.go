@@ -0,0 +1,72 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	webhookTimeout = 10 * time.Second
+)
+
+// notify posts a message to the configured discord channel.
+func (control *Control) notify(msg string) {
+	_, err := control.discordSession.ChannelMessageSend(control.Config.DiscordChannelID, msg)
+	if err != nil {
+		log.Errorf("discord: failed to send notification: %s", err)
+	}
+}
+
+// alert notifies the admins in the discord channel and through the alert webhook if configured.
+func (control *Control) alert(msg string) {
+	control.notify(fmt.Sprintf("<@&%s> %s", control.Config.DiscordAdminRoleID, msg))
+
+	if control.Config.AlertWebhookURL == "" {
+		return
+	}
+
+	err := postWebhook(control.Config.AlertWebhookURL, msg)
+	if err != nil {
+		log.Errorf("failed to send alert webhook: %s", err)
+	}
+}
+
+// postWebhook sends the message in the discord webhook format, which is understood by many other services too.
+func postWebhook(url, msg string) error {
+	body, err := json.Marshal(struct {
+		Content string `json:"content"`
+	}{
+		msg,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrNotOwner     = errors.New("not the owner")
+	ErrInvalidOwner = errors.New("invalid owner")
+)
+
+type TransferOwnershipRequest struct {
+	ServerName string `json:"serverName"`
+	Owner      string `json:"owner"`
+}
+
+// serviceOwner returns the owner of the service, which is kept on the running server, its snapshots and its volume.
+func (control *Control) serviceOwner(ctx context.Context, serviceName string) (string, error) {
+	server, _, err := control.hclient.Server.Get(ctx, serviceName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get server %s by name: %s", serviceName, err)
+	}
+
+	if server != nil && server.Labels[LabelOwner] != "" {
+		return server.Labels[LabelOwner], nil
+	}
+
+	images, err := control.listImages(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list images: %s", err)
+	}
+
+	for _, image := range images {
+		if image.Labels[LabelService] == serviceName && image.Labels[LabelOwner] != "" {
+			return image.Labels[LabelOwner], nil
+		}
+	}
+
+	volume, err := control.getVolume(ctx, serviceName)
+	if err != nil {
+		return "", err
+	}
+
+	if volume != nil {
+		return volume.Labels[LabelOwner], nil
+	}
+
+	return "", nil
+}
+
+// checkServerOwner fails if owner scoped permissions are enabled and the actor neither owns nor started the server,
+// admins may control every server.
+func (control *Control) checkServerOwner(ctx context.Context, actor, serverName string) error {
+	if !control.Config.RestrictToOwner {
+		return nil
+	}
+
+	member, err := control.guildMember(actor)
+	if err != nil {
+		return err
+	}
+
+	if member == nil || memberHasRole(member, control.Config.DiscordAdminRoleID) {
+		return nil
+	}
+
+	server, _, err := control.hclient.Server.Get(ctx, serverName)
+	if err != nil {
+		return fmt.Errorf("failed to get server %s by name: %s", serverName, err)
+	}
+
+	if server == nil {
+		return nil
+	}
+
+	if server.Labels[LabelOwner] == actor || server.Labels[LabelStartedBy] == actor {
+		return nil
+	}
+
+	return fmt.Errorf("%w: server %s is owned by someone else", ErrNotOwner, serverName)
+}
+
+// transferOwnership sets the owner on the running server, the snapshots and the volume of the service.
+func (control *Control) transferOwnership(ctx context.Context, req TransferOwnershipRequest) error {
+	if req.Owner == "" {
+		return fmt.Errorf("%w: the new owner must be set", ErrInvalidOwner)
+	}
+
+	// the owner is checked before any label is changed, so a failure can not leave different owners behind
+	member, err := control.guildMember(req.Owner)
+	if err != nil || member == nil {
+		return fmt.Errorf("%w: %s is not a member of the guild", ErrInvalidOwner, req.Owner)
+	}
+
+	found := false
+
+	server, _, err := control.hclient.Server.Get(ctx, req.ServerName)
+	if err != nil {
+		return fmt.Errorf("failed to get server %s by name: %s", req.ServerName, err)
+	}
+
+	if server != nil && server.Labels[LabelManagedBy] == LabelValueMangedByControl {
+		found = true
+		server.Labels[LabelOwner] = req.Owner
+
+		_, _, err = control.hclient.Server.Update(ctx, server, hcloud.ServerUpdateOpts{Labels: server.Labels})
+		if err != nil {
+			return fmt.Errorf("failed to update server %s: %s", req.ServerName, err)
+		}
+	}
+
+	images, err := control.listImages(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list images: %s", err)
+	}
+
+	for _, image := range images {
+		if image.Labels[LabelService] != req.ServerName {
+			continue
+		}
+
+		found = true
+		image.Labels[LabelOwner] = req.Owner
+
+		_, _, err = control.hclient.Image.Update(ctx, image, hcloud.ImageUpdateOpts{Labels: image.Labels})
+		if err != nil {
+			return fmt.Errorf("failed to update image for server %s: %s", req.ServerName, err)
+		}
+	}
+
+	volume, err := control.getVolume(ctx, req.ServerName)
+	if err != nil {
+		return err
+	}
+
+	if volume != nil {
+		found = true
+		volume.Labels[LabelOwner] = req.Owner
+
+		_, _, err = control.hclient.Volume.Update(ctx, volume, hcloud.VolumeUpdateOpts{Labels: volume.Labels})
+		if err != nil {
+			return fmt.Errorf("failed to update volume of server %s: %s", req.ServerName, err)
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("service %s does not exist", req.ServerName)
+	}
+
+	log.Infof("transferred ownership of service %s to %s", req.ServerName, req.Owner)
+
+	return nil
+}
+
+func ownerLine(owner string) string {
+	if owner == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("Owner: <@%s>\n", owner)
+}
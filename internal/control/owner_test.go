@@ -0,0 +1,78 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCheckServerOwner(t *testing.T) {
+	tests := []struct {
+		name            string
+		restrictToOwner bool
+		roles           []string
+		labels          map[string]string
+		noServer        bool
+		err             error
+	}{
+		{name: "not restricted", roles: []string{testPowerRoleID}, labels: map[string]string{LabelOwner: "other"}},
+		{name: "owner", restrictToOwner: true, roles: []string{testPowerRoleID}, labels: map[string]string{LabelOwner: "actor"}},
+		{name: "started by the actor", restrictToOwner: true, roles: []string{testPowerRoleID}, labels: map[string]string{LabelOwner: "other", LabelStartedBy: "actor"}},
+		{name: "admin", restrictToOwner: true, roles: []string{testAdminRoleID}, labels: map[string]string{LabelOwner: "other"}},
+		{name: "server not running", restrictToOwner: true, roles: []string{testPowerRoleID}, noServer: true},
+		{name: "owned by someone else", restrictToOwner: true, roles: []string{testPowerRoleID}, labels: map[string]string{LabelOwner: "other", LabelStartedBy: "other"}, err: ErrNotOwner},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var servers []map[string]any
+			if !tt.noServer {
+				servers = append(servers, testServer(1, "minecraft", tt.labels))
+			}
+
+			control := newTestControl(t, &Config{RestrictToOwner: tt.restrictToOwner}, map[string]any{
+				"GET /servers":            testServers(servers...),
+				testMemberRoute + "actor": testMember("actor", tt.roles...),
+			})
+
+			err := control.checkServerOwner(context.Background(), "actor", "minecraft")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestTransferOwnershipInvalidOwner(t *testing.T) {
+	updated := false
+
+	control := newTestControl(t, &Config{}, map[string]any{
+		"GET /servers": testServers(testServer(1, "minecraft", map[string]string{LabelOwner: "actor"})),
+		"PUT /servers/1": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			updated = true
+			http.Error(w, "unexpected update", http.StatusBadRequest)
+		}),
+	})
+
+	tests := []struct {
+		name  string
+		owner string
+	}{
+		{name: "empty", owner: ""},
+		{name: "not a guild member", owner: "stranger"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := control.transferOwnership(context.Background(), TransferOwnershipRequest{ServerName: "minecraft", Owner: tt.owner})
+			if !errors.Is(err, ErrInvalidOwner) {
+				t.Fatalf("expected %v, got %v", ErrInvalidOwner, err)
+			}
+
+			if updated {
+				t.Error("expected no label to be changed")
+			}
+		})
+	}
+}
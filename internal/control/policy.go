@@ -0,0 +1,207 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+var (
+	ErrNotAllowed = errors.New("not allowed")
+)
+
+// RolePolicy restricts what members of a discord role may do, empty lists do not restrict anything.
+type RolePolicy struct {
+	ServerTypes []string `json:"serverTypes,omitempty"`
+	Locations   []string `json:"locations,omitempty"`
+}
+
+// ServerTypeCatalogEntry describes a server type a user may choose together with its prices per location.
+type ServerTypeCatalogEntry struct {
+	Name         string               `json:"name"`
+	Description  string               `json:"description"`
+	Cores        int                  `json:"cores"`
+	Memory       float32              `json:"memory"`
+	Disk         int                  `json:"disk"`
+	CPUType      string               `json:"cpuType"`
+	Architecture string               `json:"architecture"`
+	Prices       []ServerTypeLocation `json:"prices"`
+}
+
+type ServerTypeLocation struct {
+	Location string `json:"location"`
+	Hourly   string `json:"hourly"`
+	Monthly  string `json:"monthly"`
+	Currency string `json:"currency"`
+}
+
+// LoadRolePolicies reads the roles file, a JSON object keyed by discord role id.
+func LoadRolePolicies(path string) (map[string]*RolePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read roles file: %s", err)
+	}
+
+	policies := make(map[string]*RolePolicy)
+
+	err = json.Unmarshal(data, &policies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse roles file: %s", err)
+	}
+
+	return policies, nil
+}
+
+// guildMember returns the guild member of the user, it is nil for actions without a user like the daemon.
+func (control *Control) guildMember(userID string) (*discordgo.Member, error) {
+	if userID == "" {
+		return nil, nil
+	}
+
+	member, err := control.discordSession.GuildMember(control.Config.DiscordGuildID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guild member %s: %s", userID, err)
+	}
+
+	return member, nil
+}
+
+// allowedChoices returns the union of the values the roles of the member allow,
+// it returns nil if the member is not restricted at all.
+func (control *Control) allowedChoices(member *discordgo.Member, choices func(policy *RolePolicy) []string) []string {
+	if member == nil || memberHasRole(member, control.Config.DiscordAdminRoleID) {
+		return nil
+	}
+
+	var allowed []string
+	restricted := false
+
+	for _, roleID := range member.Roles {
+		policy, ok := control.Config.RolePolicies[roleID]
+		if !ok || policy == nil {
+			continue
+		}
+
+		values := choices(policy)
+		if len(values) == 0 {
+			// an unrestricted role wins over restricted ones
+			return nil
+		}
+
+		restricted = true
+		allowed = append(allowed, values...)
+	}
+
+	if !restricted {
+		return nil
+	}
+
+	slices.Sort(allowed)
+
+	return slices.Compact(allowed)
+}
+
+func (control *Control) allowedServerTypes(member *discordgo.Member) []string {
+	return control.allowedChoices(member, func(policy *RolePolicy) []string { return policy.ServerTypes })
+}
+
+func (control *Control) allowedLocations(member *discordgo.Member) []string {
+	return control.allowedChoices(member, func(policy *RolePolicy) []string { return policy.Locations })
+}
+
+// checkServerTypeAllowed fails if the roles of the actor do not allow the server type or location.
+func (control *Control) checkServerTypeAllowed(actor, serverType, location string) error {
+	member, err := control.guildMember(actor)
+	if err != nil {
+		return err
+	}
+
+	if allowed := control.allowedServerTypes(member); allowed != nil && !slices.Contains(allowed, serverType) {
+		return fmt.Errorf("%w: server type %s, allowed are %v", ErrNotAllowed, serverType, allowed)
+	}
+
+	if allowed := control.allowedLocations(member); allowed != nil && location != "" && !slices.Contains(allowed, location) {
+		return fmt.Errorf("%w: location %s, allowed are %v", ErrNotAllowed, location, allowed)
+	}
+
+	return nil
+}
+
+// serverTypeCatalog lists the server types the actor may choose with their prices in the allowed locations.
+func (control *Control) serverTypeCatalog(ctx context.Context, actor string) ([]ServerTypeCatalogEntry, error) {
+	member, err := control.guildMember(actor)
+	if err != nil {
+		return nil, err
+	}
+
+	serverTypes, err := control.hclient.ServerType.All(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list server types: %s", err)
+	}
+
+	allowedTypes := control.allowedServerTypes(member)
+	allowedLocations := control.allowedLocations(member)
+
+	var catalog []ServerTypeCatalogEntry
+
+	for _, serverType := range serverTypes {
+		if allowedTypes != nil && !slices.Contains(allowedTypes, serverType.Name) {
+			continue
+		}
+
+		entry := ServerTypeCatalogEntry{
+			Name:         serverType.Name,
+			Description:  serverType.Description,
+			Cores:        serverType.Cores,
+			Memory:       serverType.Memory,
+			Disk:         serverType.Disk,
+			CPUType:      string(serverType.CPUType),
+			Architecture: string(serverType.Architecture),
+		}
+
+		for _, pricing := range serverType.Pricings {
+			if allowedLocations != nil && !slices.Contains(allowedLocations, pricing.Location.Name) {
+				continue
+			}
+
+			entry.Prices = append(entry.Prices, ServerTypeLocation{
+				Location: pricing.Location.Name,
+				Hourly:   pricing.Hourly.Gross,
+				Monthly:  pricing.Monthly.Gross,
+				Currency: pricing.Hourly.Currency,
+			})
+		}
+
+		if len(entry.Prices) == 0 {
+			continue
+		}
+
+		catalog = append(catalog, entry)
+	}
+
+	return catalog, nil
+}
+
+// location returns the location by name, an empty name means the configured default location.
+func (control *Control) location(name string) *hcloud.Location {
+	if name == "" {
+		return control.Config.Location
+	}
+
+	return &hcloud.Location{Name: name}
+}
+
+// serverLocationName returns the location of the server, falling back to the configured location.
+func (control *Control) serverLocationName(server *hcloud.Server) string {
+	if server.Location == nil {
+		return control.Config.Location.Name
+	}
+
+	return server.Location.Name
+}
@@ -0,0 +1,173 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	log "github.com/sirupsen/logrus"
+)
+
+// servicePrimaryIPs returns the reserved primary ips of the service and allocates them on first use.
+// The ips are not deleted with the server, they stay unassigned until the service is destroyed.
+// The ipv4 is nil if the service is ipv6 only.
+func (control *Control) servicePrimaryIPs(ctx context.Context, serviceName string, withIPv4 bool, location *hcloud.Location) (*hcloud.PrimaryIP, *hcloud.PrimaryIP, error) {
+	primaryIPs, err := control.listPrimaryIPs(ctx, serviceName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ipv4, ipv6 *hcloud.PrimaryIP
+
+	for _, primaryIP := range primaryIPs {
+		// a reserved ipv4 of a service which became ipv6 only stays unassigned
+		if primaryIP.Type == hcloud.PrimaryIPTypeIPv4 && !withIPv4 {
+			continue
+		}
+
+		// primary ips can only be assigned to servers in their own location
+		if primaryIP.Location != nil && primaryIP.Location.Name != location.Name {
+			return nil, nil, fmt.Errorf("primary ip %s is in location %s, not %s", primaryIP.IP, primaryIP.Location.Name, location.Name)
+		}
+
+		switch primaryIP.Type {
+		case hcloud.PrimaryIPTypeIPv4:
+			ipv4 = primaryIP
+		case hcloud.PrimaryIPTypeIPv6:
+			ipv6 = primaryIP
+		}
+	}
+
+	if ipv4 == nil && withIPv4 {
+		ipv4, err = control.createPrimaryIP(ctx, serviceName, hcloud.PrimaryIPTypeIPv4, location)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if ipv6 == nil {
+		ipv6, err = control.createPrimaryIP(ctx, serviceName, hcloud.PrimaryIPTypeIPv6, location)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return ipv4, ipv6, nil
+}
+
+// servicePublicNet returns the public network settings for creating a server of the service,
+// it is nil if the service neither uses reserved primary ips nor is ipv6 only.
+func (control *Control) servicePublicNet(ctx context.Context, serviceName string, location *hcloud.Location) (*hcloud.ServerCreatePublicNet, error) {
+	svc := control.serviceConfig(serviceName)
+
+	if !svc.PrimaryIPs && !svc.IPv6Only {
+		return nil, nil
+	}
+
+	publicNet := &hcloud.ServerCreatePublicNet{
+		EnableIPv4: !svc.IPv6Only,
+		EnableIPv6: true,
+	}
+
+	if svc.PrimaryIPs {
+		var err error
+		publicNet.IPv4, publicNet.IPv6, err = control.servicePrimaryIPs(ctx, serviceName, !svc.IPv6Only, location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get primary ips of service %s: %s", serviceName, err)
+		}
+	}
+
+	return publicNet, nil
+}
+
+// listPrimaryIPs returns the managed primary ips of the service or of all services if the name is empty.
+func (control *Control) listPrimaryIPs(ctx context.Context, serviceName string) ([]*hcloud.PrimaryIP, error) {
+	labelSelector := fmt.Sprintf("%s=%s", LabelManagedBy, LabelValueMangedByControl)
+	if serviceName != "" {
+		labelSelector += fmt.Sprintf(",%s=%s", LabelService, serviceName)
+	}
+
+	primaryIPs, err := control.hclient.PrimaryIP.AllWithOpts(ctx, hcloud.PrimaryIPListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: labelSelector},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list primary ips: %s", err)
+	}
+
+	return primaryIPs, nil
+}
+
+func (control *Control) createPrimaryIP(ctx context.Context, serviceName string, ipType hcloud.PrimaryIPType, location *hcloud.Location) (*hcloud.PrimaryIP, error) {
+	result, _, err := control.hclient.PrimaryIP.Create(ctx, hcloud.PrimaryIPCreateOpts{
+		Name:         fmt.Sprintf("%s-%s", serviceName, ipType),
+		Type:         ipType,
+		AssigneeType: "server",
+		AutoDelete:   new(false),
+		Location:     location.Name,
+		Labels: map[string]string{
+			LabelManagedBy: LabelValueMangedByControl,
+			LabelService:   serviceName,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s primary ip for service %s: %s", ipType, serviceName, err)
+	}
+
+	if result.Action != nil {
+		err = control.hclient.Action.WaitFor(ctx, result.Action)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s primary ip for service %s: %s", ipType, serviceName, err)
+		}
+	}
+
+	log.Infof("allocated %s primary ip %s for service %s", ipType, result.PrimaryIP.IP, serviceName)
+
+	return result.PrimaryIP, nil
+}
+
+func (control *Control) deletePrimaryIPs(ctx context.Context, serviceName string) error {
+	primaryIPs, err := control.listPrimaryIPs(ctx, serviceName)
+	if err != nil {
+		return err
+	}
+
+	for _, primaryIP := range primaryIPs {
+		_, err = control.hclient.PrimaryIP.Delete(ctx, primaryIP)
+		if err != nil {
+			return fmt.Errorf("failed to delete primary ip %s of service %s: %s", primaryIP.IP, serviceName, err)
+		}
+
+		log.Infof("deleted primary ip %s of service %s", primaryIP.IP, serviceName)
+	}
+
+	return nil
+}
+
+// primaryIPMonthlyCost returns the gross monthly price of the primary ips.
+func primaryIPMonthlyCost(pricing hcloud.Pricing, primaryIPs []*hcloud.PrimaryIP) (float64, error) {
+	var total float64
+
+	for _, primaryIP := range primaryIPs {
+		for _, typePricing := range pricing.PrimaryIPs {
+			if typePricing.Type != string(primaryIP.Type) {
+				continue
+			}
+
+			for _, locationPricing := range typePricing.Pricings {
+				if locationPricing.Location != primaryIP.Location.Name {
+					continue
+				}
+
+				price, err := strconv.ParseFloat(locationPricing.Monthly.Gross, 64)
+				if err != nil {
+					return 0, fmt.Errorf("failed to parse primary ip price: %s", err)
+				}
+
+				total += price
+			}
+		}
+	}
+
+	return total, nil
+}
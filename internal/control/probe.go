@@ -0,0 +1,284 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ProbeTypeTCP  = "tcp"
+	ProbeTypeUDP  = "udp"
+	ProbeTypeHTTP = "http"
+	ProbeTypeA2S  = "a2s"
+
+	defaultReadinessTimeout = 10 * time.Minute
+	readinessInterval       = 10 * time.Second
+	probeTimeout            = 5 * time.Second
+)
+
+var (
+	a2sInfoRequest = []byte("\xFF\xFF\xFF\xFFTSource Engine Query\x00")
+)
+
+type ProbeConfig struct {
+	Type    string `json:"type"`
+	Host    string `json:"host,omitempty"`
+	Port    int    `json:"port"`
+	Path    string `json:"path,omitempty"`
+	Payload string `json:"payload,omitempty"`
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// check runs the probe once against the given host.
+func (probe *ProbeConfig) check(ctx context.Context, host string) error {
+	if probe.Host != "" {
+		host = probe.Host
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(probe.Port))
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	switch probe.Type {
+	case ProbeTypeTCP:
+		return probeTCP(ctx, addr)
+	case ProbeTypeUDP:
+		return probeUDP(ctx, addr, []byte(probe.Payload))
+	case ProbeTypeHTTP:
+		return probeHTTP(ctx, fmt.Sprintf("http://%s%s", addr, probe.Path))
+	case ProbeTypeA2S:
+		return probeA2S(ctx, addr)
+	default:
+		return fmt.Errorf("unknown probe type %s", probe.Type)
+	}
+}
+
+// waitForReady probes the server until it is ready or the readiness timeout has passed and records the result
+// in the ready label.
+func (control *Control) waitForReady(ctx context.Context, server *hcloud.Server) error {
+	probe := control.serviceConfig(server.Name).Readiness
+	if probe == nil {
+		return nil
+	}
+
+	timeout := defaultReadinessTimeout
+
+	if probe.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(probe.Timeout)
+		if err != nil {
+			return fmt.Errorf("failed to parse readiness timeout: %s", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(readinessInterval)
+	defer ticker.Stop()
+
+	host := serverAddress(server)
+
+	readyErr := func() error {
+		for {
+			err := probe.check(ctx, host)
+			if err == nil {
+				log.Infof("server %s is ready", server.Name)
+				return nil
+			}
+
+			log.Debugf("server %s is not ready yet: %s", server.Name, err)
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return fmt.Errorf("server %s did not become ready within %s: %s", server.Name, timeout, err)
+			}
+		}
+	}()
+
+	// the labels might have changed while waiting, e.g. by an extend, and the context might be gone already
+	current, _, err := control.hclient.Server.GetByID(context.Background(), server.ID)
+	if err != nil || current == nil {
+		log.Errorf("failed to get server %s to update its ready label: %v", server.Name, err)
+		return readyErr
+	}
+
+	current.Labels[LabelReady] = strconv.FormatBool(readyErr == nil)
+
+	_, _, err = control.hclient.Server.Update(context.Background(), current, hcloud.ServerUpdateOpts{Labels: current.Labels})
+	if err != nil {
+		log.Errorf("failed to update ready label of server %s: %s", server.Name, err)
+	}
+
+	return readyErr
+}
+
+// readinessPending reports whether the service has a readiness probe the server has not passed yet.
+func (control *Control) readinessPending(server *hcloud.Server) bool {
+	return control.serviceConfig(server.Name).Readiness != nil && server.Labels[LabelReady] != "true"
+}
+
+// watchReadiness waits for the server to become ready in the background and calls done with the result,
+// done is called right away if there is nothing to wait for.
+func (control *Control) watchReadiness(server *hcloud.Server, done func(err error)) {
+	if !control.readinessPending(server) {
+		done(nil)
+		return
+	}
+
+	go func() {
+		done(control.waitForReady(context.Background(), server))
+	}()
+}
+
+// readinessStatus starts watching the readiness of the created server and returns the status to answer with,
+// which is accepted while the server is not ready yet. The result of the check is shown by the ready label.
+func (control *Control) readinessStatus(server *hcloud.Server) int {
+	if !control.readinessPending(server) {
+		return http.StatusCreated
+	}
+
+	control.watchReadiness(server, func(err error) {
+		if err != nil {
+			log.Errorf("readiness check failed for server %s: %s", server.Name, err)
+		}
+	})
+
+	return http.StatusAccepted
+}
+
+// replier returns an announce function replying in the channel of the message, it might be called after the
+// command has been handled.
+func (control *Control) replier(s *discordgo.Session, m *discordgo.Message) func(msg string) {
+	return func(msg string) {
+		_, err := s.ChannelMessageSend(m.ChannelID, msg)
+		if err != nil {
+			log.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, err)
+		}
+	}
+}
+
+// announceWhenReady announces the started server with the message once it is ready, so nobody connects
+// to a server which is still booting. A failed readiness check is announced to the admins instead.
+func (control *Control) announceWhenReady(server *hcloud.Server, announce func(msg string), readyMsg string) {
+	if control.readinessPending(server) {
+		announce(fmt.Sprintf("Server %s has been created, waiting for it to become ready", server.Name))
+	}
+
+	control.watchReadiness(server, func(err error) {
+		if err != nil {
+			log.Errorf("readiness check failed for server %s: %s", server.Name, err)
+			announce(fmt.Sprintf(
+				"Server %s is running but failed its readiness check, <@&%s> please have a look",
+				server.Name,
+				control.Config.DiscordAdminRoleID,
+			))
+			return
+		}
+
+		announce(readyMsg)
+	})
+}
+
+func probeTCP(ctx context.Context, addr string) error {
+	dialer := &net.Dialer{}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+// probeUDP sends the payload and expects any answer, as there is no handshake for udp.
+func probeUDP(ctx context.Context, addr string, payload []byte) error {
+	_, err := exchangeUDP(ctx, addr, payload)
+	return err
+}
+
+func probeHTTP(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// probeA2S sends a source engine A2S_INFO query, answering a challenge if the server asks for one.
+func probeA2S(ctx context.Context, addr string) error {
+	resp, err := exchangeUDP(ctx, addr, a2sInfoRequest)
+	if err != nil {
+		return err
+	}
+
+	if len(resp) >= 9 && resp[4] == 0x41 {
+		resp, err = exchangeUDP(ctx, addr, append(bytes.Clone(a2sInfoRequest), resp[5:9]...))
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(resp) < 5 || resp[4] != 0x49 {
+		return errors.New("unexpected a2s info response")
+	}
+
+	return nil
+}
+
+func exchangeUDP(ctx context.Context, addr string, payload []byte) ([]byte, error) {
+	dialer := &net.Dialer{}
+
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(probeTimeout)
+	}
+
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.Write(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 1400)
+
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf[:n], nil
+}
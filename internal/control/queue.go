@@ -0,0 +1,283 @@
+package control
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// the queue is processed whenever a slot might have been freed, the check catches everything else
+	queueCheckInterval = time.Minute
+)
+
+var (
+	ErrNotQueued = errors.New("server is not queued")
+)
+
+// QueuedStart is a start request waiting for a free server slot, new servers carry their server type.
+type QueuedStart struct {
+	ID          string    `json:"id"`
+	ServerName  string    `json:"serverName"`
+	ServerType  string    `json:"serverType,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	TTL         string    `json:"ttl"`
+	New         bool      `json:"new,omitempty"`
+	RequestedBy string    `json:"requestedBy,omitempty"`
+	Queued      time.Time `json:"queued"`
+	Position    int       `json:"position"`
+}
+
+// QueuedError is returned when all server slots are in use and the start request has been queued.
+type QueuedError struct {
+	Entry *QueuedStart
+}
+
+func (err *QueuedError) Error() string {
+	return fmt.Sprintf("all server slots are in use, server %s is queued at position %d", err.Entry.ServerName, err.Entry.Position)
+}
+
+// startSlots counts the servers which are being created but might not be listed yet.
+type startSlots struct {
+	mutex    sync.Mutex
+	starting int
+}
+
+// reserveStartSlot reserves a slot for creating the server or queues the request if all slots are in use
+// or other requests are waiting already. The returned release function must be called once the server
+// has been created or creating it failed.
+func (control *Control) reserveStartSlot(ctx context.Context, entry *QueuedStart) (func(), error) {
+	if control.Config.MaxServers <= 0 {
+		return func() {}, nil
+	}
+
+	control.startSlots.mutex.Lock()
+	defer control.startSlots.mutex.Unlock()
+
+	servers, err := control.listServers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list servers: %s", err)
+	}
+
+	waiting := 0
+	control.state.view(func(state *persistentState) {
+		waiting = len(state.StartQueue)
+	})
+
+	if waiting == 0 && len(servers)+control.startSlots.starting < control.Config.MaxServers {
+		control.startSlots.starting++
+		return control.releaseStartSlot, nil
+	}
+
+	return nil, control.enqueueStart(entry)
+}
+
+// releaseStartSlot frees the reserved slot, a queued start takes it over if creating the server failed.
+func (control *Control) releaseStartSlot() {
+	control.startSlots.mutex.Lock()
+	control.startSlots.starting--
+	control.startSlots.mutex.Unlock()
+
+	go control.processStartQueue(context.Background())
+}
+
+func (control *Control) enqueueStart(entry *QueuedStart) error {
+	buf := make([]byte, 8)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return fmt.Errorf("failed to create queue id: %s", err)
+	}
+
+	entry.ID = hex.EncodeToString(buf)
+	entry.Queued = time.Now()
+
+	var queued bool
+
+	err = control.state.update(func(state *persistentState) {
+		if slices.ContainsFunc(state.StartQueue, func(queued *QueuedStart) bool { return queued.ServerName == entry.ServerName }) {
+			queued = true
+			return
+		}
+
+		state.StartQueue = append(state.StartQueue, entry)
+		entry.Position = len(state.StartQueue)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save start queue: %s", err)
+	}
+
+	if queued {
+		return fmt.Errorf("server %s is already queued", entry.ServerName)
+	}
+
+	log.Infof("queue: queued start of server %s at position %d", entry.ServerName, entry.Position)
+
+	return &QueuedError{Entry: entry}
+}
+
+// startQueue returns the waiting start requests with their positions.
+func (control *Control) startQueue() []*QueuedStart {
+	var queue []*QueuedStart
+
+	control.state.view(func(state *persistentState) {
+		for i, entry := range state.StartQueue {
+			queued := *entry
+			queued.Position = i + 1
+			queue = append(queue, &queued)
+		}
+	})
+
+	return queue
+}
+
+// dequeueStart cancels the queued start of the server, only admins may cancel requests of other users.
+func (control *Control) dequeueStart(serverName, actor string, isAdmin bool) error {
+	var found, allowed bool
+
+	err := control.state.update(func(state *persistentState) {
+		i := slices.IndexFunc(state.StartQueue, func(entry *QueuedStart) bool { return entry.ServerName == serverName })
+		if i < 0 {
+			return
+		}
+
+		found = true
+		if !isAdmin && state.StartQueue[i].RequestedBy != actor {
+			return
+		}
+
+		allowed = true
+		state.StartQueue = slices.Delete(state.StartQueue, i, i+1)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save start queue: %s", err)
+	}
+
+	if !found {
+		return ErrNotQueued
+	}
+
+	if !allowed {
+		return ErrUnauthorized
+	}
+
+	log.Infof("queue: cancelled start of server %s", serverName)
+
+	return nil
+}
+
+// processStartQueue starts queued servers as long as there are free slots, it is called whenever a slot
+// might have been freed.
+func (control *Control) processStartQueue(ctx context.Context) {
+	if control.Config.MaxServers <= 0 {
+		return
+	}
+
+	for {
+		entry, err := control.takeQueuedStart(ctx)
+		if err != nil {
+			log.Errorf("queue error: %s", err)
+			return
+		}
+
+		if entry == nil {
+			return
+		}
+
+		go control.runQueuedStart(context.Background(), entry)
+	}
+}
+
+// takeQueuedStart removes the first queued start and reserves a slot for it, it returns nil if there is
+// nothing to start or no free slot.
+func (control *Control) takeQueuedStart(ctx context.Context) (*QueuedStart, error) {
+	control.startSlots.mutex.Lock()
+	defer control.startSlots.mutex.Unlock()
+
+	waiting := 0
+	control.state.view(func(state *persistentState) {
+		waiting = len(state.StartQueue)
+	})
+
+	if waiting == 0 {
+		return nil, nil
+	}
+
+	servers, err := control.listServers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list servers: %s", err)
+	}
+
+	if len(servers)+control.startSlots.starting >= control.Config.MaxServers {
+		return nil, nil
+	}
+
+	var entry *QueuedStart
+
+	err = control.state.update(func(state *persistentState) {
+		if len(state.StartQueue) > 0 {
+			entry = state.StartQueue[0]
+			state.StartQueue = state.StartQueue[1:]
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save start queue: %s", err)
+	}
+
+	if entry != nil {
+		control.startSlots.starting++
+	}
+
+	return entry, nil
+}
+
+// runQueuedStart starts the server in the reserved slot and announces the result to the requester.
+func (control *Control) runQueuedStart(ctx context.Context, entry *QueuedStart) {
+	defer control.releaseStartSlot()
+
+	log.Infof("queue: starting queued server %s", entry.ServerName)
+
+	var server *hcloud.Server
+	var err error
+
+	if entry.New {
+		server, err = control.newServer(ctx, entry.RequestedBy, CreateNewServerRequest{
+			ServerName:   entry.ServerName,
+			ServerType:   entry.ServerType,
+			Location:     entry.Location,
+			TTL:          entry.TTL,
+			Confirm:      true,
+			slotReserved: true,
+		})
+	} else {
+		server, err = control.startServer(ctx, entry.RequestedBy, StartServerRequest{
+			ServerName:   entry.ServerName,
+			TTL:          entry.TTL,
+			Confirm:      true,
+			slotReserved: true,
+		})
+	}
+
+	mention := ""
+	if entry.RequestedBy != "" {
+		mention = fmt.Sprintf("<@%s> ", entry.RequestedBy)
+	}
+
+	if err != nil {
+		log.Errorf("queue error: failed to start queued server %s: %s", entry.ServerName, err)
+		control.notify(fmt.Sprintf("%sfailed to start server %s from the queue.", mention, entry.ServerName))
+		return
+	}
+
+	control.announceWhenReady(server, func(msg string) {
+		control.notify(mention + msg)
+	}, fmt.Sprintf("Server %s from the queue is ready with DNS %s. It will run for %s", entry.ServerName, serverDNSPtr(server), entry.TTL))
+}
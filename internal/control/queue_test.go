@@ -0,0 +1,171 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestReserveStartSlot(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxServers int
+		running    int
+		starting   int
+		queued     []string
+		reserved   bool
+		position   int
+	}{
+		{name: "unlimited", maxServers: 0, running: 5, reserved: true},
+		{name: "free slot", maxServers: 2, running: 1, reserved: true},
+		{name: "all slots running", maxServers: 2, running: 2, position: 1},
+		// servers which are being created are not listed yet, but take a slot
+		{name: "slot taken by a starting server", maxServers: 2, running: 1, starting: 1, position: 1},
+		// a free slot belongs to the requests which are waiting already
+		{name: "others waiting", maxServers: 2, running: 1, queued: []string{"factorio"}, position: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var servers []map[string]any
+			for i := range tt.running {
+				servers = append(servers, testServer(int64(i+1), fmt.Sprintf("server-%d", i), nil))
+			}
+
+			control := newTestControl(t, &Config{MaxServers: tt.maxServers}, map[string]any{
+				"GET /servers": testServers(servers...),
+			})
+			control.startSlots.starting = tt.starting
+
+			for _, serverName := range tt.queued {
+				control.state.state.StartQueue = append(control.state.state.StartQueue, &QueuedStart{ServerName: serverName})
+			}
+
+			release, err := control.reserveStartSlot(context.Background(), &QueuedStart{ServerName: "minecraft", TTL: "2h"})
+
+			if tt.reserved {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+
+				expectedStarting := tt.starting
+				if tt.maxServers > 0 {
+					expectedStarting++
+				}
+				if control.startSlots.starting != expectedStarting {
+					t.Errorf("expected %d starting servers, got %d", expectedStarting, control.startSlots.starting)
+				}
+
+				if release == nil {
+					t.Error("expected a release function")
+				}
+				return
+			}
+
+			var queuedErr *QueuedError
+			if !errors.As(err, &queuedErr) {
+				t.Fatalf("expected the start to be queued, got %v", err)
+			}
+
+			if queuedErr.Entry.Position != tt.position {
+				t.Errorf("expected position %d, got %d", tt.position, queuedErr.Entry.Position)
+			}
+
+			if control.startSlots.starting != tt.starting {
+				t.Errorf("expected %d starting servers, got %d", tt.starting, control.startSlots.starting)
+			}
+		})
+	}
+}
+
+func TestEnqueueStartTwice(t *testing.T) {
+	control := newTestControl(t, &Config{MaxServers: 1}, nil)
+
+	var queuedErr *QueuedError
+
+	err := control.enqueueStart(&QueuedStart{ServerName: "minecraft"})
+	if !errors.As(err, &queuedErr) {
+		t.Fatalf("expected the start to be queued, got %v", err)
+	}
+
+	err = control.enqueueStart(&QueuedStart{ServerName: "minecraft"})
+	if err == nil || errors.As(err, &queuedErr) {
+		t.Fatalf("expected the second start of the same server to be refused, got %v", err)
+	}
+
+	if queue := control.startQueue(); len(queue) != 1 {
+		t.Errorf("expected one queued start, got %d", len(queue))
+	}
+}
+
+func TestTakeQueuedStart(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxServers int
+		running    int
+		starting   int
+		queued     []string
+		taken      string
+	}{
+		{name: "empty queue", maxServers: 2, running: 0},
+		{name: "free slot", maxServers: 2, running: 1, queued: []string{"minecraft", "factorio"}, taken: "minecraft"},
+		{name: "no free slot", maxServers: 2, running: 2, queued: []string{"minecraft"}},
+		{name: "slot taken by a starting server", maxServers: 2, running: 1, starting: 1, queued: []string{"minecraft"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var servers []map[string]any
+			for i := range tt.running {
+				servers = append(servers, testServer(int64(i+1), fmt.Sprintf("server-%d", i), nil))
+			}
+
+			control := newTestControl(t, &Config{MaxServers: tt.maxServers}, map[string]any{
+				"GET /servers": testServers(servers...),
+			})
+			control.startSlots.starting = tt.starting
+
+			for _, serverName := range tt.queued {
+				control.state.state.StartQueue = append(control.state.state.StartQueue, &QueuedStart{ServerName: serverName})
+			}
+
+			entry, err := control.takeQueuedStart(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if tt.taken == "" {
+				if entry != nil {
+					t.Fatalf("expected no queued start to be taken, got %s", entry.ServerName)
+				}
+				if control.startSlots.starting != tt.starting {
+					t.Errorf("expected %d starting servers, got %d", tt.starting, control.startSlots.starting)
+				}
+				if queue := control.startQueue(); len(queue) != len(tt.queued) {
+					t.Errorf("expected %d queued starts, got %d", len(tt.queued), len(queue))
+				}
+				return
+			}
+
+			if entry == nil || entry.ServerName != tt.taken {
+				t.Fatalf("expected %s to be taken, got %v", tt.taken, entry)
+			}
+
+			if control.startSlots.starting != tt.starting+1 {
+				t.Errorf("expected the taken start to reserve a slot, got %d starting servers", control.startSlots.starting)
+			}
+
+			queue := control.startQueue()
+			if len(queue) != len(tt.queued)-1 {
+				t.Fatalf("expected %d queued starts, got %d", len(tt.queued)-1, len(queue))
+			}
+
+			for i, entry := range queue {
+				if entry.Position != i+1 {
+					t.Errorf("expected %s at position %d, got %d", entry.ServerName, i+1, entry.Position)
+				}
+			}
+		})
+	}
+}
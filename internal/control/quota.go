@@ -0,0 +1,236 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+// QuotaConfig limits what users may run, user quotas take precedence over role quotas.
+type QuotaConfig struct {
+	Roles map[string]*Quota `json:"roles,omitempty"`
+	Users map[string]*Quota `json:"users,omitempty"`
+}
+
+// Quota holds the limits of a role or user, zero values do not limit anything.
+type Quota struct {
+	MaxTTL       string  `json:"maxTTL,omitempty"`
+	MaxExtension string  `json:"maxExtension,omitempty"`
+	MaxServers   int     `json:"maxServers,omitempty"`
+	DailyHours   float64 `json:"dailyHours,omitempty"`
+}
+
+// LoadQuotaConfig reads the quotas file.
+func LoadQuotaConfig(path string) (*QuotaConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quotas file: %s", err)
+	}
+
+	quotas := &QuotaConfig{}
+
+	err = json.Unmarshal(data, quotas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse quotas file: %s", err)
+	}
+
+	for _, quotaMap := range []map[string]*Quota{quotas.Roles, quotas.Users} {
+		for id, quota := range quotaMap {
+			_, _, err := quota.durations()
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse quota of %s: %s", id, err)
+			}
+		}
+	}
+
+	return quotas, nil
+}
+
+func (quota *Quota) durations() (time.Duration, time.Duration, error) {
+	var maxTTL, maxExtension time.Duration
+	var err error
+
+	if quota.MaxTTL != "" {
+		maxTTL, err = time.ParseDuration(quota.MaxTTL)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to parse max ttl: %s", err)
+		}
+	}
+
+	if quota.MaxExtension != "" {
+		maxExtension, err = time.ParseDuration(quota.MaxExtension)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to parse max extension: %s", err)
+		}
+	}
+
+	return maxTTL, maxExtension, nil
+}
+
+// memberLimits are the effective limits of a member, zero values do not limit anything.
+type memberLimits struct {
+	maxTTL       time.Duration
+	maxExtension time.Duration
+	maxServers   int
+	dailyHours   float64
+}
+
+// memberLimits merges the quotas of the member, every limit of the user quota takes precedence
+// and otherwise the most generous role quota applies.
+func (control *Control) memberLimits(member *discordgo.Member) memberLimits {
+	limits := memberLimits{maxTTL: MaxTTL}
+
+	quotas := control.Config.Quotas
+	if quotas == nil || member == nil || memberHasRole(member, control.Config.DiscordAdminRoleID) {
+		return limits
+	}
+
+	var roleLimits memberLimits
+
+	for _, roleID := range member.Roles {
+		quota, ok := quotas.Roles[roleID]
+		if !ok || quota == nil {
+			continue
+		}
+
+		maxTTL, maxExtension, _ := quota.durations()
+		roleLimits.maxTTL = max(roleLimits.maxTTL, maxTTL)
+		roleLimits.maxExtension = max(roleLimits.maxExtension, maxExtension)
+		roleLimits.maxServers = max(roleLimits.maxServers, quota.MaxServers)
+		roleLimits.dailyHours = max(roleLimits.dailyHours, quota.DailyHours)
+	}
+
+	if roleLimits.maxTTL > 0 {
+		limits.maxTTL = roleLimits.maxTTL
+	}
+	limits.maxExtension = roleLimits.maxExtension
+	limits.maxServers = roleLimits.maxServers
+	limits.dailyHours = roleLimits.dailyHours
+
+	if quota, ok := quotas.Users[member.User.ID]; ok && quota != nil {
+		maxTTL, maxExtension, _ := quota.durations()
+		if maxTTL > 0 {
+			limits.maxTTL = maxTTL
+		}
+		if maxExtension > 0 {
+			limits.maxExtension = maxExtension
+		}
+		if quota.MaxServers > 0 {
+			limits.maxServers = quota.MaxServers
+		}
+		if quota.DailyHours > 0 {
+			limits.dailyHours = quota.DailyHours
+		}
+	}
+
+	return limits
+}
+
+// checkStartQuota refuses to start a server for the duration if the actor would exceed the maximum ttl,
+// the number of concurrently running servers or the daily runtime hours.
+func (control *Control) checkStartQuota(ctx context.Context, actor string, duration time.Duration) error {
+	member, err := control.guildMember(actor)
+	if err != nil {
+		return err
+	}
+
+	limits := control.memberLimits(member)
+
+	if duration > limits.maxTTL {
+		return fmt.Errorf("%w: the ttl must not exceed %s", ErrQuotaExceeded, limits.maxTTL)
+	}
+
+	if limits.maxServers > 0 {
+		servers, err := control.listServers(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list servers: %s", err)
+		}
+
+		running := 0
+		for _, server := range servers {
+			if server.Labels[LabelStartedBy] == actor {
+				running++
+			}
+		}
+
+		if running >= limits.maxServers {
+			return fmt.Errorf("%w: you are already running %d of %d servers", ErrQuotaExceeded, running, limits.maxServers)
+		}
+	}
+
+	return control.checkDailyHours(actor, limits, duration)
+}
+
+// checkExtendQuota refuses to extend a server by the duration if it exceeds the maximum extension,
+// the remaining ttl would exceed the maximum ttl or the actor would exceed the daily runtime hours.
+func (control *Control) checkExtendQuota(actor string, duration, remaining time.Duration) error {
+	member, err := control.guildMember(actor)
+	if err != nil {
+		return err
+	}
+
+	limits := control.memberLimits(member)
+
+	if remaining > limits.maxTTL {
+		return fmt.Errorf("%w: server cannot be extended beyond %s", ErrQuotaExceeded, limits.maxTTL)
+	}
+
+	if duration <= 0 {
+		return nil
+	}
+
+	if limits.maxExtension > 0 && duration > limits.maxExtension {
+		return fmt.Errorf("%w: a server can be extended by %s at most", ErrQuotaExceeded, limits.maxExtension)
+	}
+
+	return control.checkDailyHours(actor, limits, duration)
+}
+
+// checkDailyHours counts the runtime of the servers the actor started today, the day starts at midnight UTC.
+func (control *Control) checkDailyHours(actor string, limits memberLimits, duration time.Duration) error {
+	if limits.dailyHours <= 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var used time.Duration
+
+	control.state.view(func(state *persistentState) {
+		for _, session := range state.Sessions {
+			if session.StartedBy != actor {
+				continue
+			}
+
+			start, end := session.Start, now
+			if session.End != nil {
+				end = *session.End
+			}
+			if start.Before(dayStart) {
+				start = dayStart
+			}
+			if end.After(start) {
+				used += end.Sub(start)
+			}
+		}
+	})
+
+	if used.Hours()+duration.Hours() > limits.dailyHours {
+		return fmt.Errorf(
+			"%w: your daily runtime of %.0f hours would be exceeded (%.1f used, %.1f requested)",
+			ErrQuotaExceeded, limits.dailyHours, used.Hours(), duration.Hours(),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var testQuotas = &QuotaConfig{
+	Roles: map[string]*Quota{
+		testUserRoleID:  {MaxTTL: "4h", MaxExtension: "1h", MaxServers: 1, DailyHours: 6},
+		testPowerRoleID: {MaxTTL: "12h", MaxServers: 2},
+	},
+	Users: map[string]*Quota{
+		"vip": {MaxTTL: "24h", DailyHours: 12},
+	},
+}
+
+func TestMemberLimits(t *testing.T) {
+	control := &Control{Config: &Config{DiscordAdminRoleID: testAdminRoleID, Quotas: testQuotas}}
+
+	tests := []struct {
+		name     string
+		userID   string
+		roles    []string
+		expected memberLimits
+	}{
+		{name: "no quota", userID: "someone", roles: []string{"other"}, expected: memberLimits{maxTTL: MaxTTL}},
+		{name: "admin", userID: "someone", roles: []string{testAdminRoleID, testUserRoleID}, expected: memberLimits{maxTTL: MaxTTL}},
+		{name: "role", userID: "someone", roles: []string{testUserRoleID}, expected: memberLimits{maxTTL: 4 * time.Hour, maxExtension: time.Hour, maxServers: 1, dailyHours: 6}},
+		// every limit takes the most generous role
+		{name: "roles", userID: "someone", roles: []string{testUserRoleID, testPowerRoleID}, expected: memberLimits{maxTTL: 12 * time.Hour, maxExtension: time.Hour, maxServers: 2, dailyHours: 6}},
+		// the user quota overrides the limits it sets and keeps the others of the roles
+		{name: "user", userID: "vip", roles: []string{testUserRoleID}, expected: memberLimits{maxTTL: 24 * time.Hour, maxExtension: time.Hour, maxServers: 1, dailyHours: 12}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := control.memberLimits(&discordgo.Member{User: &discordgo.User{ID: tt.userID}, Roles: tt.roles})
+			if limits != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, limits)
+			}
+		})
+	}
+}
+
+func TestCheckDailyHours(t *testing.T) {
+	now := time.Now().UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	// the session started yesterday only counts since midnight, sessions of others do not count
+	used := min(now.Sub(dayStart), time.Hour)
+	sessions := []*Session{
+		{Service: "minecraft", StartedBy: "actor", Start: dayStart.Add(-5 * time.Hour), End: new(dayStart.Add(used))},
+		{Service: "valheim", StartedBy: "actor", Start: dayStart.Add(-10 * time.Hour), End: new(dayStart.Add(-5 * time.Hour))},
+		{Service: "factorio", StartedBy: "other", Start: dayStart},
+	}
+
+	tests := []struct {
+		name       string
+		dailyHours float64
+		duration   time.Duration
+		err        error
+	}{
+		{name: "unlimited", dailyHours: 0, duration: 100 * time.Hour},
+		{name: "within", dailyHours: 6, duration: 6*time.Hour - used - time.Minute},
+		{name: "exceeded", dailyHours: 6, duration: 6*time.Hour - used + time.Minute, err: ErrQuotaExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			control := &Control{Config: &Config{}, state: &stateStore{state: persistentState{Sessions: sessions}}}
+
+			err := control.checkDailyHours("actor", memberLimits{dailyHours: tt.dailyHours}, tt.duration)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestCheckStartQuota(t *testing.T) {
+	tests := []struct {
+		name     string
+		roles    []string
+		running  int
+		duration time.Duration
+		err      error
+	}{
+		{name: "within", roles: []string{testPowerRoleID}, running: 1, duration: 8 * time.Hour},
+		{name: "ttl too long", roles: []string{testUserRoleID}, duration: 5 * time.Hour, err: ErrQuotaExceeded},
+		{name: "too many servers", roles: []string{testPowerRoleID}, running: 2, duration: time.Hour, err: ErrQuotaExceeded},
+		{name: "admin", roles: []string{testAdminRoleID}, running: 5, duration: 20 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var servers []map[string]any
+			for i := range tt.running {
+				servers = append(servers, testServer(int64(i+1), "server", map[string]string{LabelStartedBy: "actor"}))
+			}
+			servers = append(servers, testServer(100, "other", map[string]string{LabelStartedBy: "other"}))
+
+			control := newTestControl(t, &Config{Quotas: testQuotas}, map[string]any{
+				"GET /servers":            testServers(servers...),
+				testMemberRoute + "actor": testMember("actor", tt.roles...),
+			})
+
+			err := control.checkStartQuota(context.Background(), "actor", tt.duration)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestCheckExtendQuota(t *testing.T) {
+	tests := []struct {
+		name      string
+		duration  time.Duration
+		remaining time.Duration
+		err       error
+	}{
+		{name: "within", duration: time.Hour, remaining: 3 * time.Hour},
+		{name: "extension too long", duration: 2 * time.Hour, remaining: 3 * time.Hour, err: ErrQuotaExceeded},
+		{name: "beyond the max ttl", duration: time.Hour, remaining: 5 * time.Hour, err: ErrQuotaExceeded},
+		// shortening the ttl is always allowed within the max ttl
+		{name: "shortened", duration: -time.Hour, remaining: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			control := newTestControl(t, &Config{Quotas: testQuotas}, map[string]any{
+				testMemberRoute + "actor": testMember("actor", testUserRoleID),
+			})
+
+			err := control.checkExtendQuota("actor", tt.duration, tt.remaining)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
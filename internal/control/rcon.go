@@ -0,0 +1,80 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mycreepy/mnbcontrol/internal/rcon"
+)
+
+const (
+	rconTimeout = 10 * time.Second
+)
+
+type RCONConfig struct {
+	Host     string   `json:"host,omitempty"`
+	Port     int      `json:"port"`
+	Password string   `json:"password"`
+	Protocol string   `json:"protocol,omitempty"`
+	RoleIDs  []string `json:"roleIDs,omitempty"`
+}
+
+func (control *Control) executeRCON(ctx context.Context, actor string, req RCONRequest) (string, error) {
+	rconConfig := control.serviceConfig(req.ServerName).RCON
+	if rconConfig == nil {
+		return "", fmt.Errorf("rcon is not configured for server %s", req.ServerName)
+	}
+
+	if req.Command == "" {
+		return "", errors.New("rcon command can not be empty")
+	}
+
+	host := rconConfig.Host
+
+	if host == "" {
+		server, _, err := control.hclient.Server.Get(ctx, req.ServerName)
+		if err != nil {
+			return "", fmt.Errorf("failed to get server %s by name: %s", req.ServerName, err)
+		}
+
+		if server == nil {
+			return "", errors.New("server does not exist")
+		}
+
+		host = serverAddress(server)
+	}
+
+	control.audit(actor, "rcon", req.ServerName, req.Command)
+
+	client, err := rcon.Dial(ctx, net.JoinHostPort(host, strconv.Itoa(rconConfig.Port)), rconConfig.Password, rcon.Protocol(rconConfig.Protocol), rconTimeout)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to rcon of server %s: %s", req.ServerName, err)
+	}
+	defer client.Close()
+
+	output, err := client.Execute(req.Command)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute rcon command on server %s: %s", req.ServerName, err)
+	}
+
+	return output, nil
+}
+
+// truncateRCONOutput cuts the output to at most limit bytes at the start of a rune, so no character is split.
+func truncateRCONOutput(output string, limit int) string {
+	if len(output) <= limit {
+		return output
+	}
+
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(output[cut]) {
+		cut--
+	}
+
+	return output[:cut] + "\n..."
+}
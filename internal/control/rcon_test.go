@@ -0,0 +1,44 @@
+package control
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateRCONOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		limit    int
+		expected string
+	}{
+		{name: "short", output: "ok", limit: 5, expected: "ok"},
+		{name: "exact", output: "hello", limit: 5, expected: "hello"},
+		{name: "ascii", output: "hello world", limit: 5, expected: "hello\n..."},
+		// ä is two bytes, the cut at byte 2 would split it
+		{name: "two byte rune", output: "aäb", limit: 2, expected: "a\n..."},
+		{name: "rune ends at limit", output: "aäb", limit: 3, expected: "aä\n..."},
+		// € is three bytes
+		{name: "three byte rune", output: "€€", limit: 4, expected: "€\n..."},
+		{name: "first rune too long", output: "€", limit: 2, expected: "\n..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			truncated := truncateRCONOutput(tt.output, tt.limit)
+
+			if truncated != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, truncated)
+			}
+
+			if !utf8.ValidString(truncated) {
+				t.Errorf("expected valid utf-8, got %q", truncated)
+			}
+
+			if cut, _ := strings.CutSuffix(truncated, "\n..."); len(cut) > tt.limit {
+				t.Errorf("expected at most %d bytes, got %d", tt.limit, len(cut))
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	rfc2136Timeout = 10 * time.Second
+	tsigFudge      = 300
+)
+
+// rfc2136DNSProvider sends dynamic updates to an authoritative name server, optionally signed with tsig.
+type rfc2136DNSProvider struct {
+	server        string
+	zone          string
+	tsigKeyName   string
+	tsigSecret    string
+	tsigAlgorithm string
+}
+
+func (provider *rfc2136DNSProvider) SetRecord(ctx context.Context, record DNSRecord) error {
+	recordType, ok := dns.StringToType[record.Type]
+	if !ok {
+		return fmt.Errorf("unknown record type %s", record.Type)
+	}
+
+	fqdn := provider.fqdn(record.Name)
+
+	rrs := make([]dns.RR, 0, len(record.Values))
+
+	for _, value := range record.Values {
+		rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", fqdn, record.TTL, record.Type, value))
+		if err != nil {
+			return fmt.Errorf("failed to parse record %s %s: %s", fqdn, record.Type, err)
+		}
+		rrs = append(rrs, rr)
+	}
+
+	msg := new(dns.Msg)
+	msg.SetUpdate(dns.Fqdn(provider.zone))
+	msg.RemoveRRset([]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: fqdn, Rrtype: recordType, Class: dns.ClassANY}}})
+	msg.Insert(rrs)
+
+	return provider.exchange(ctx, msg)
+}
+
+func (provider *rfc2136DNSProvider) DeleteRecord(ctx context.Context, name, recordType string) error {
+	rrtype, ok := dns.StringToType[recordType]
+	if !ok {
+		return fmt.Errorf("unknown record type %s", recordType)
+	}
+
+	msg := new(dns.Msg)
+	msg.SetUpdate(dns.Fqdn(provider.zone))
+	msg.RemoveRRset([]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: provider.fqdn(name), Rrtype: rrtype, Class: dns.ClassANY}}})
+
+	return provider.exchange(ctx, msg)
+}
+
+func (provider *rfc2136DNSProvider) exchange(ctx context.Context, msg *dns.Msg) error {
+	client := &dns.Client{Net: "tcp", Timeout: rfc2136Timeout}
+
+	if provider.tsigKeyName != "" {
+		keyName := dns.Fqdn(provider.tsigKeyName)
+
+		algorithm := dns.HmacSHA256
+		if provider.tsigAlgorithm != "" {
+			algorithm = dns.Fqdn(provider.tsigAlgorithm)
+		}
+
+		client.TsigSecret = map[string]string{keyName: provider.tsigSecret}
+		msg.SetTsig(keyName, algorithm, tsigFudge, time.Now().Unix())
+	}
+
+	resp, _, err := client.ExchangeContext(ctx, msg, provider.server)
+	if err != nil {
+		return fmt.Errorf("dns update failed: %s", err)
+	}
+
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("dns update failed: %s", dns.RcodeToString[resp.Rcode])
+	}
+
+	return nil
+}
+
+func (provider *rfc2136DNSProvider) fqdn(name string) string {
+	return dns.Fqdn(name + "." + provider.zone)
+}
@@ -0,0 +1,211 @@
+package control
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	testZone       = "example.com"
+	testTSIGKey    = "mnbcontrol."
+	testTSIGSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQtc2VjcmV0LXNlY3JldA=="
+)
+
+// testDNSServer is an in-process name server which records the updates it receives,
+// updates with an invalid tsig signature are answered with NOTAUTH.
+type testDNSServer struct {
+	addr    string
+	mutex   sync.Mutex
+	updates []*dns.Msg
+}
+
+func startTestDNSServer(t *testing.T) *testDNSServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+
+	testServer := &testDNSServer{addr: listener.Addr().String()}
+
+	started := make(chan struct{})
+
+	server := &dns.Server{
+		Listener:          listener,
+		TsigSecret:        map[string]string{testTSIGKey: testTSIGSecret},
+		NotifyStartedFunc: func() { close(started) },
+		// the default accept func rejects updates
+		MsgAcceptFunc: func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			resp := new(dns.Msg)
+			resp.SetReply(req)
+
+			tsig := req.IsTsig()
+			if tsig == nil || w.TsigStatus() != nil {
+				resp.Rcode = dns.RcodeNotAuth
+				_ = w.WriteMsg(resp)
+				return
+			}
+
+			testServer.mutex.Lock()
+			testServer.updates = append(testServer.updates, req)
+			testServer.mutex.Unlock()
+
+			resp.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsigFudge, int64(tsig.TimeSigned))
+
+			_ = w.WriteMsg(resp)
+		}),
+	}
+
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+
+	<-started
+
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+
+	return testServer
+}
+
+func (testServer *testDNSServer) lastUpdate(t *testing.T) *dns.Msg {
+	t.Helper()
+
+	testServer.mutex.Lock()
+	defer testServer.mutex.Unlock()
+
+	if len(testServer.updates) == 0 {
+		t.Fatal("no update received")
+	}
+
+	return testServer.updates[len(testServer.updates)-1]
+}
+
+func newTestRFC2136Provider(addr, secret string) *rfc2136DNSProvider {
+	return &rfc2136DNSProvider{
+		server:      addr,
+		zone:        testZone,
+		tsigKeyName: testTSIGKey,
+		tsigSecret:  secret,
+	}
+}
+
+func TestRFC2136SetRecordCreates(t *testing.T) {
+	testServer := startTestDNSServer(t)
+	provider := newTestRFC2136Provider(testServer.addr, testTSIGSecret)
+
+	err := provider.SetRecord(context.Background(), DNSRecord{
+		Name:   "minecraft",
+		Type:   "A",
+		TTL:    60,
+		Values: []string{"192.0.2.1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	update := testServer.lastUpdate(t)
+
+	if update.Opcode != dns.OpcodeUpdate {
+		t.Errorf("expected update opcode, got %s", dns.OpcodeToString[update.Opcode])
+	}
+
+	if len(update.Question) != 1 || update.Question[0].Name != "example.com." || update.Question[0].Qtype != dns.TypeSOA {
+		t.Errorf("expected zone example.com., got %v", update.Question)
+	}
+
+	if len(update.Ns) != 2 {
+		t.Fatalf("expected a removal and one insert, got %v", update.Ns)
+	}
+
+	removal := update.Ns[0].Header()
+	if removal.Name != "minecraft.example.com." || removal.Class != dns.ClassANY || removal.Rrtype != dns.TypeA {
+		t.Errorf("expected removal of the A record set, got %s", update.Ns[0])
+	}
+
+	a, ok := update.Ns[1].(*dns.A)
+	if !ok || a.Hdr.Name != "minecraft.example.com." || a.Hdr.Ttl != 60 || a.A.String() != "192.0.2.1" {
+		t.Errorf("expected A record minecraft.example.com. 60 192.0.2.1, got %s", update.Ns[1])
+	}
+}
+
+func TestRFC2136SetRecordUpdates(t *testing.T) {
+	testServer := startTestDNSServer(t)
+	provider := newTestRFC2136Provider(testServer.addr, testTSIGSecret)
+
+	err := provider.SetRecord(context.Background(), DNSRecord{
+		Name:   "minecraft",
+		Type:   "AAAA",
+		TTL:    300,
+		Values: []string{"2001:db8::1", "2001:db8::2"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	update := testServer.lastUpdate(t)
+
+	// the record set is replaced as a whole, so values of a previous server are removed
+	if len(update.Ns) != 3 {
+		t.Fatalf("expected a removal and two inserts, got %v", update.Ns)
+	}
+
+	if header := update.Ns[0].Header(); header.Class != dns.ClassANY || header.Rrtype != dns.TypeAAAA {
+		t.Errorf("expected removal of the AAAA record set, got %s", update.Ns[0])
+	}
+
+	for i, expected := range []string{"2001:db8::1", "2001:db8::2"} {
+		aaaa, ok := update.Ns[i+1].(*dns.AAAA)
+		if !ok || aaaa.Hdr.Ttl != 300 || aaaa.AAAA.String() != expected {
+			t.Errorf("expected AAAA record 300 %s, got %s", expected, update.Ns[i+1])
+		}
+	}
+}
+
+func TestRFC2136DeleteRecord(t *testing.T) {
+	testServer := startTestDNSServer(t)
+	provider := newTestRFC2136Provider(testServer.addr, testTSIGSecret)
+
+	err := provider.DeleteRecord(context.Background(), "minecraft", "A")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	update := testServer.lastUpdate(t)
+
+	if len(update.Ns) != 1 {
+		t.Fatalf("expected one removal, got %v", update.Ns)
+	}
+
+	header := update.Ns[0].Header()
+	if header.Name != "minecraft.example.com." || header.Class != dns.ClassANY || header.Rrtype != dns.TypeA {
+		t.Errorf("expected removal of the A record set, got %s", update.Ns[0])
+	}
+}
+
+func TestRFC2136InvalidTSIG(t *testing.T) {
+	testServer := startTestDNSServer(t)
+	provider := newTestRFC2136Provider(testServer.addr, "d3Jvbmctc2VjcmV0LXdyb25nLXNlY3JldC13cm9uZw==")
+
+	err := provider.DeleteRecord(context.Background(), "minecraft", "A")
+	if err == nil || !strings.Contains(err.Error(), "NOTAUTH") {
+		t.Fatalf("expected the update with an invalid signature to be rejected, got %v", err)
+	}
+}
+
+func TestRFC2136UnknownRecordType(t *testing.T) {
+	provider := newTestRFC2136Provider("127.0.0.1:0", testTSIGSecret)
+
+	err := provider.SetRecord(context.Background(), DNSRecord{Name: "minecraft", Type: "NOPE", Values: []string{"x"}})
+	if err == nil {
+		t.Fatal("expected an unknown record type to fail")
+	}
+}
@@ -0,0 +1,364 @@
+package control
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrNoSchedule = errors.New("schedule does not exist")
+)
+
+// Schedule starts or stops a service at the times of a cron expression in the time zone.
+type Schedule struct {
+	ID         string     `json:"id"`
+	ServerName string     `json:"serverName"`
+	Action     string     `json:"action"`
+	Cron       string     `json:"cron"`
+	TimeZone   string     `json:"timeZone"`
+	TTL        string     `json:"ttl,omitempty"`
+	CreatedBy  string     `json:"createdBy"`
+	Created    time.Time  `json:"created"`
+	Next       *time.Time `json:"next,omitempty"`
+}
+
+type CreateScheduleRequest struct {
+	ServerName string `json:"serverName"`
+	Action     string `json:"action"`
+	Cron       string `json:"cron"`
+	TimeZone   string `json:"timeZone"`
+	TTL        string `json:"ttl,omitempty"`
+}
+
+// cronSchedules runs the persisted schedules, the entries map the schedule ids to their cron entries.
+type cronSchedules struct {
+	mutex   sync.Mutex
+	cron    *cron.Cron
+	entries map[string]cron.EntryID
+}
+
+func newCronSchedules() *cronSchedules {
+	return &cronSchedules{
+		cron:    cron.New(),
+		entries: make(map[string]cron.EntryID),
+	}
+}
+
+// parseSchedule parses the cron expression in the time zone, an empty time zone means UTC.
+func parseSchedule(spec, timeZone string) (cron.Schedule, error) {
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+
+	_, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %s", timeZone)
+	}
+
+	schedule, err := cron.ParseStandard(fmt.Sprintf("CRON_TZ=%s %s", timeZone, spec))
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %s: %s", spec, err)
+	}
+
+	return schedule, nil
+}
+
+// addSchedule validates, persists and registers a new schedule, stops may only be scheduled by the owner of the server.
+func (control *Control) addSchedule(ctx context.Context, actor string, req CreateScheduleRequest) (*Schedule, error) {
+	if req.ServerName == "" {
+		return nil, errors.New("server name must be set")
+	}
+
+	switch req.Action {
+	case ActionStart:
+		if req.TTL == "" {
+			req.TTL = "12h"
+		}
+
+		_, err := time.ParseDuration(req.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ttl %s", req.TTL)
+		}
+	case ActionStop:
+		req.TTL = ""
+
+		err := control.checkServerOwner(ctx, actor, req.ServerName)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid action %s, must be %s or %s", req.Action, ActionStart, ActionStop)
+	}
+
+	if req.TimeZone == "" {
+		req.TimeZone = "UTC"
+	}
+
+	_, err := parseSchedule(req.Cron, req.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 8)
+
+	_, err = rand.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schedule id: %s", err)
+	}
+
+	schedule := &Schedule{
+		ID:         hex.EncodeToString(buf),
+		ServerName: req.ServerName,
+		Action:     req.Action,
+		Cron:       req.Cron,
+		TimeZone:   req.TimeZone,
+		TTL:        req.TTL,
+		CreatedBy:  actor,
+		Created:    time.Now(),
+	}
+
+	err = control.state.update(func(state *persistentState) {
+		state.Schedules = append(state.Schedules, schedule)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save schedule: %s", err)
+	}
+
+	err = control.registerSchedule(*schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("schedule: user %s added schedule %s to %s server %s at %s", actor, schedule.ID, schedule.Action, schedule.ServerName, schedule.Cron)
+
+	return schedule, nil
+}
+
+// registerSchedule adds the cron entry of the schedule.
+func (control *Control) registerSchedule(schedule Schedule) error {
+	parsed, err := parseSchedule(schedule.Cron, schedule.TimeZone)
+	if err != nil {
+		return err
+	}
+
+	control.schedules.mutex.Lock()
+	defer control.schedules.mutex.Unlock()
+
+	control.schedules.entries[schedule.ID] = control.schedules.cron.Schedule(parsed, cron.FuncJob(func() {
+		control.runSchedule(context.Background(), schedule)
+	}))
+
+	return nil
+}
+
+// loadSchedules registers the persisted schedules and starts running them.
+func (control *Control) loadSchedules() {
+	var schedules []Schedule
+
+	control.state.view(func(state *persistentState) {
+		for _, schedule := range state.Schedules {
+			schedules = append(schedules, *schedule)
+		}
+	})
+
+	for _, schedule := range schedules {
+		err := control.registerSchedule(schedule)
+		if err != nil {
+			log.Errorf("schedule error: failed to register schedule %s: %s", schedule.ID, err)
+		}
+	}
+
+	control.schedules.cron.Start()
+}
+
+// listSchedules returns the schedules with their next run sorted by server name.
+func (control *Control) listSchedules() []*Schedule {
+	schedules := []*Schedule{}
+
+	control.state.view(func(state *persistentState) {
+		for _, schedule := range state.Schedules {
+			copied := *schedule
+			schedules = append(schedules, &copied)
+		}
+	})
+
+	control.schedules.mutex.Lock()
+	for _, schedule := range schedules {
+		entryID, ok := control.schedules.entries[schedule.ID]
+		if !ok {
+			continue
+		}
+
+		next := control.schedules.cron.Entry(entryID).Next
+		if !next.IsZero() {
+			schedule.Next = &next
+		}
+	}
+	control.schedules.mutex.Unlock()
+
+	slices.SortFunc(schedules, func(a, b *Schedule) int {
+		return cmp.Or(strings.Compare(a.ServerName, b.ServerName), a.Created.Compare(b.Created))
+	})
+
+	return schedules
+}
+
+// visibleSchedules returns the schedules of the services the member may view.
+func (control *Control) visibleSchedules(member *discordgo.Member) []*Schedule {
+	return slices.DeleteFunc(control.listSchedules(), func(schedule *Schedule) bool {
+		return !control.memberMayAccess(member, schedule.ServerName, ActionView)
+	})
+}
+
+// checkScheduleAccess fails if the member may not take the action of the schedule on its service.
+func (control *Control) checkScheduleAccess(member *discordgo.Member, id string) error {
+	var schedule *Schedule
+
+	control.state.view(func(state *persistentState) {
+		for _, s := range state.Schedules {
+			if s.ID == id {
+				copied := *s
+				schedule = &copied
+			}
+		}
+	})
+
+	if schedule == nil {
+		return fmt.Errorf("%w: %s", ErrNoSchedule, id)
+	}
+
+	return control.checkAccess(member, schedule.ServerName, schedule.Action)
+}
+
+// removeSchedule removes the schedule from the state and stops running it.
+func (control *Control) removeSchedule(id string) (*Schedule, error) {
+	var removed *Schedule
+
+	err := control.state.update(func(state *persistentState) {
+		state.Schedules = slices.DeleteFunc(state.Schedules, func(schedule *Schedule) bool {
+			if schedule.ID != id {
+				return false
+			}
+
+			removed = schedule
+			return true
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save schedules: %s", err)
+	}
+
+	if removed == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoSchedule, id)
+	}
+
+	control.schedules.mutex.Lock()
+	if entryID, ok := control.schedules.entries[id]; ok {
+		control.schedules.cron.Remove(entryID)
+		delete(control.schedules.entries, id)
+	}
+	control.schedules.mutex.Unlock()
+
+	log.Infof("schedule: removed schedule %s of server %s", removed.ID, removed.ServerName)
+
+	return removed, nil
+}
+
+// runSchedule starts or stops the server and announces the result in the discord channel.
+func (control *Control) runSchedule(ctx context.Context, schedule Schedule) {
+	log.Infof("schedule: running schedule %s to %s server %s", schedule.ID, schedule.Action, schedule.ServerName)
+
+	// the creator might have lost their role or access since the schedule was added
+	member, err := control.guildMember(schedule.CreatedBy)
+	if err != nil {
+		log.Errorf("schedule error: %s", err)
+		control.notify(fmt.Sprintf("The scheduled %s of server %s failed.", schedule.Action, schedule.ServerName))
+		return
+	}
+
+	if member == nil ||
+		!memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID) ||
+		!control.memberMayAccess(member, schedule.ServerName, schedule.Action) {
+		log.Infof("schedule: creator %s of schedule %s may not %s server %s anymore", schedule.CreatedBy, schedule.ID, schedule.Action, schedule.ServerName)
+		control.notify(fmt.Sprintf("The scheduled %s of server %s was skipped, its creator may not %s it anymore.", schedule.Action, schedule.ServerName, schedule.Action))
+		return
+	}
+
+	switch schedule.Action {
+	case ActionStart:
+		server, err := control.startServer(ctx, schedule.CreatedBy, StartServerRequest{
+			ServerName: schedule.ServerName,
+			TTL:        schedule.TTL,
+			Confirm:    true,
+		})
+
+		var queuedErr *QueuedError
+
+		switch {
+		case errors.As(err, &queuedErr):
+			control.notify(fmt.Sprintf("Scheduled start: %s.", queuedErr))
+		case err != nil:
+			log.Errorf("schedule error: failed to start server %s: %s", schedule.ServerName, err)
+			control.notify(fmt.Sprintf("The scheduled start of server %s failed.", schedule.ServerName))
+		default:
+			control.announceWhenReady(server, func(msg string) {
+				control.notify("Scheduled start: " + msg)
+			}, fmt.Sprintf(
+				"Server %s is ready with DNS %s. It will run for %s",
+				server.Name,
+				serverDNSPtr(server),
+				schedule.TTL,
+			))
+		}
+	case ActionStop:
+		if !control.scheduler.beginTermination(schedule.ServerName) {
+			log.Infof("schedule: termination of server %s already in progress", schedule.ServerName)
+			return
+		}
+		defer control.scheduler.endTermination(schedule.ServerName)
+
+		server, _, err := control.hclient.Server.Get(ctx, schedule.ServerName)
+		if err != nil {
+			log.Errorf("schedule error: failed to get server %s by name: %s", schedule.ServerName, err)
+			return
+		}
+
+		if server == nil {
+			log.Infof("schedule: server %s is not running, nothing to stop", schedule.ServerName)
+			return
+		}
+
+		// the server might have been started by someone else than the creator of the schedule
+		err = control.checkServerOwner(ctx, schedule.CreatedBy, schedule.ServerName)
+		if errors.Is(err, ErrNotOwner) {
+			log.Infof("schedule: skipping stop of server %s: %s", schedule.ServerName, err)
+			control.notify(fmt.Sprintf("The scheduled stop of server %s was skipped, it is owned by someone else.", schedule.ServerName))
+			return
+		}
+		if err != nil {
+			log.Errorf("schedule error: %s", err)
+			return
+		}
+
+		err = control.terminateServer(ctx, schedule.ServerName)
+		if err != nil {
+			log.Errorf("schedule error: failed to terminate server %s: %s", schedule.ServerName, err)
+			control.notify(fmt.Sprintf("The scheduled stop of server %s failed.", schedule.ServerName))
+			return
+		}
+
+		control.notify(fmt.Sprintf("Scheduled stop: server %s has been stopped.", schedule.ServerName))
+	}
+}
@@ -0,0 +1,101 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// a wednesday
+	from := time.Date(2026, time.January, 7, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		spec     string
+		timeZone string
+		expected time.Time
+		err      bool
+	}{
+		{name: "utc by default", spec: "0 18 * * *", expected: time.Date(2026, time.January, 7, 18, 0, 0, 0, time.UTC)},
+		{name: "utc", spec: "30 8 * * *", timeZone: "UTC", expected: time.Date(2026, time.January, 8, 8, 30, 0, 0, time.UTC)},
+		// 18:00 in berlin is 17:00 utc in winter
+		{name: "time zone", spec: "0 18 * * *", timeZone: "Europe/Berlin", expected: time.Date(2026, time.January, 7, 17, 0, 0, 0, time.UTC)},
+		{name: "weekday", spec: "0 20 * * FRI", timeZone: "UTC", expected: time.Date(2026, time.January, 9, 20, 0, 0, 0, time.UTC)},
+		{name: "descriptor", spec: "@daily", timeZone: "UTC", expected: time.Date(2026, time.January, 8, 0, 0, 0, 0, time.UTC)},
+		{name: "invalid time zone", spec: "0 18 * * *", timeZone: "Mars/Olympus", err: true},
+		{name: "invalid expression", spec: "every evening", err: true},
+		{name: "seconds are not supported", spec: "0 0 18 * * *", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseSchedule(tt.spec, tt.timeZone)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if next := schedule.Next(from); !next.Equal(tt.expected) {
+				t.Errorf("expected %s, got %s", tt.expected, next.UTC())
+			}
+		})
+	}
+}
+
+func TestRunScheduleCreatorAccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		roles   []string
+		access  []AccessRule
+		skipped bool
+	}{
+		{name: "power user", roles: []string{testPowerRoleID}},
+		{name: "role lost", roles: []string{testUserRoleID}, skipped: true},
+		{name: "access lost", roles: []string{testPowerRoleID}, access: []AccessRule{{RoleIDs: []string{testPowerRoleID}, Actions: []string{ActionView}}}, skipped: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			var messages []string
+
+			control := newTestControl(t, &Config{
+				DiscordChannelID: "channel",
+				Services:         map[string]*ServiceConfig{"minecraft": {Access: tt.access}},
+			}, map[string]any{
+				testMemberRoute + "creator": testMember("creator", tt.roles...),
+				"POST /api/v9/channels/channel/messages": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					var message discordgo.MessageSend
+					_ = json.NewDecoder(r.Body).Decode(&message)
+
+					mutex.Lock()
+					messages = append(messages, message.Content)
+					mutex.Unlock()
+
+					_, _ = w.Write([]byte(`{"id": "1"}`))
+				}),
+				// the stop finds no server, so nothing else is called
+				"GET /servers": testServers(),
+			})
+
+			control.runSchedule(context.Background(), Schedule{ID: "1", ServerName: "minecraft", Action: ActionStop, CreatedBy: "creator"})
+
+			skipped := len(messages) == 1 && strings.Contains(messages[0], "was skipped")
+			if skipped != tt.skipped {
+				t.Errorf("expected skipped %t, got messages %q", tt.skipped, messages)
+			}
+		})
+	}
+}
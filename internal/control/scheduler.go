@@ -0,0 +1,320 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	terminationBackoffBase = time.Minute
+	terminationBackoffMax  = time.Hour
+)
+
+// ttlScheduler keeps one timer per managed server which fires once the server reaches its ttl.
+type ttlScheduler struct {
+	mutex       sync.Mutex
+	timers      map[string]*time.Timer
+	terminating map[string]bool
+	expired     chan string
+}
+
+func newTTLScheduler() *ttlScheduler {
+	return &ttlScheduler{
+		timers:      make(map[string]*time.Timer),
+		terminating: make(map[string]bool),
+		expired:     make(chan string),
+	}
+}
+
+// schedule (re)sets the timer of the server to fire at the given ttl.
+func (scheduler *ttlScheduler) schedule(serverName string, ttl time.Time) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if timer, ok := scheduler.timers[serverName]; ok {
+		timer.Stop()
+	}
+
+	scheduler.timers[serverName] = time.AfterFunc(time.Until(ttl), func() {
+		scheduler.expired <- serverName
+	})
+
+	log.Debugf("scheduler: server %s will reach its ttl in %s -> %s", serverName, time.Until(ttl), ttl)
+}
+
+func (scheduler *ttlScheduler) unschedule(serverName string) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if timer, ok := scheduler.timers[serverName]; ok {
+		timer.Stop()
+		delete(scheduler.timers, serverName)
+	}
+}
+
+// timersSnapshot returns the current timers, so timers set afterwards can be told apart from them.
+func (scheduler *ttlScheduler) timersSnapshot() map[string]*time.Timer {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	return maps.Clone(scheduler.timers)
+}
+
+// unscheduleStale removes the timer of the server only if it has not been (re)set since the snapshot it was taken from.
+func (scheduler *ttlScheduler) unscheduleStale(serverName string, timer *time.Timer) bool {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if scheduler.timers[serverName] != timer {
+		return false
+	}
+
+	timer.Stop()
+	delete(scheduler.timers, serverName)
+
+	return true
+}
+
+func (scheduler *ttlScheduler) stop() {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	for serverName, timer := range scheduler.timers {
+		timer.Stop()
+		delete(scheduler.timers, serverName)
+	}
+}
+
+// beginTermination marks the server as terminating, it returns false if a termination is already in progress.
+func (scheduler *ttlScheduler) beginTermination(serverName string) bool {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if scheduler.terminating[serverName] {
+		return false
+	}
+
+	scheduler.terminating[serverName] = true
+
+	return true
+}
+
+func (scheduler *ttlScheduler) endTermination(serverName string) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	delete(scheduler.terminating, serverName)
+}
+
+// reconcile schedules all managed servers from their ttl labels and drops timers of servers which are gone.
+func (control *Control) reconcile(ctx context.Context) {
+	log.Debug("scheduler: reconciling ttl timers")
+
+	// the costs are queried again once per reconcile
+	control.costCache.reset()
+
+	// timers set while the servers are listed belong to servers which might be missing from the list
+	timers := control.scheduler.timersSnapshot()
+
+	managedServers, err := control.listServers(ctx)
+	if err != nil {
+		log.Errorf("scheduler error: %s", err)
+		return
+	}
+
+	existing := make(map[string]bool)
+
+	for _, s := range managedServers {
+		existing[s.Name] = true
+
+		due, err := serverTerminationDue(s)
+		if err != nil {
+			// the worker alerts the admins and terminates the server, it would run forever otherwise
+			log.Errorf("scheduler error: server %s: %s", s.Name, err)
+			due = invalidTTLDue(s)
+		}
+
+		control.scheduler.schedule(s.Name, due)
+	}
+
+	for serverName, timer := range timers {
+		if existing[serverName] {
+			continue
+		}
+
+		if control.scheduler.unscheduleStale(serverName, timer) {
+			log.Infof("scheduler: server %s does not exist anymore, removing its timer", serverName)
+		}
+	}
+}
+
+// terminateExpiredServer terminates the server if it is still past its ttl when the timer fires.
+func (control *Control) terminateExpiredServer(ctx context.Context, serverName string) {
+	if !control.scheduler.beginTermination(serverName) {
+		log.Debugf("scheduler: termination of server %s already in progress", serverName)
+		return
+	}
+	defer control.scheduler.endTermination(serverName)
+
+	server, _, err := control.hclient.Server.Get(ctx, serverName)
+	if err != nil {
+		log.Errorf("scheduler error: failed to get server %s by name: %s", serverName, err)
+		control.scheduler.schedule(serverName, time.Now().Add(terminationBackoffBase))
+		return
+	}
+
+	if server == nil {
+		control.scheduler.unschedule(serverName)
+		return
+	}
+
+	if server.Status != hcloud.ServerStatusRunning && server.Status != hcloud.ServerStatusOff {
+		log.Infof("scheduler warn: server %s is in status %s, retrying in %s", server.Name, server.Status, terminationBackoffBase)
+		control.scheduler.schedule(serverName, time.Now().Add(terminationBackoffBase))
+		return
+	}
+
+	due, ttlErr := serverTerminationDue(server)
+	if ttlErr != nil {
+		due = invalidTTLDue(server)
+	}
+
+	// the ttl might have been extended while the timer was firing
+	if time.Now().Before(due) {
+		control.scheduler.schedule(serverName, due)
+		return
+	}
+
+	if ttlErr != nil {
+		log.Errorf("scheduler error: server %s: %s, terminating now", serverName, ttlErr)
+
+		// later attempts are alerted by the termination failures
+		if server.Labels[LabelTerminationFailures] == "" {
+			control.alert(fmt.Sprintf("Server %s has an invalid ttl and is terminated: %s", serverName, ttlErr))
+		}
+	} else {
+		log.Infof("scheduler: server %s reached its ttl, terminating now", serverName)
+	}
+
+	err = control.terminateServer(ctx, serverName)
+	if err != nil {
+		log.Errorf("scheduler error: failed to terminate server %s: %s", serverName, err)
+		control.recordTerminationFailure(ctx, server, err)
+		return
+	}
+}
+
+// terminationWorker terminates expired servers until stop is closed.
+func (control *Control) terminationWorker(stop <-chan struct{}) {
+	for {
+		select {
+		case serverName := <-control.scheduler.expired:
+			control.terminateExpiredServer(context.Background(), serverName)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// recordTerminationFailure persists the failure count on the server, schedules the next
+// attempt with exponential backoff and alerts the admins every time the threshold is reached.
+func (control *Control) recordTerminationFailure(ctx context.Context, server *hcloud.Server, terminationErr error) {
+	// the server might have changed during the failed termination
+	server, _, err := control.hclient.Server.GetByID(ctx, server.ID)
+	if err != nil || server == nil {
+		log.Errorf("scheduler error: failed to get server for recording termination failure: %v", err)
+		return
+	}
+
+	failures, _ := strconv.Atoi(server.Labels[LabelTerminationFailures])
+	failures++
+
+	backoff := terminationBackoff(failures)
+	retry := time.Now().Add(backoff)
+
+	server.Labels[LabelTerminationFailures] = strconv.Itoa(failures)
+	server.Labels[LabelTerminationRetry] = strconv.Itoa(int(retry.Unix()))
+
+	_, _, err = control.hclient.Server.Update(ctx, server, hcloud.ServerUpdateOpts{Labels: server.Labels})
+	if err != nil {
+		log.Errorf("scheduler error: failed to update termination failure labels of server %s: %s", server.Name, err)
+	}
+
+	control.scheduler.schedule(server.Name, retry)
+
+	log.Infof("scheduler: termination of server %s failed %d times, retrying in %s", server.Name, failures, backoff)
+
+	threshold := control.Config.TerminationAlertAfter
+	if threshold > 0 && failures%threshold == 0 {
+		control.alert(fmt.Sprintf(
+			"Server %s failed to terminate %d times and keeps costing money: %s",
+			server.Name,
+			failures,
+			terminationErr,
+		))
+	}
+}
+
+// terminationBackoff doubles the delay of the next termination attempt with every failure up to the maximum.
+func terminationBackoff(failures int) time.Duration {
+	// the limit is applied before the conversion, as many failures overflow the duration
+	backoff := float64(terminationBackoffBase) * math.Pow(2, float64(failures-1))
+	if backoff > float64(terminationBackoffMax) {
+		return terminationBackoffMax
+	}
+
+	return time.Duration(backoff)
+}
+
+// serverTerminationDue returns the ttl of the server, or the time of the next retry after a failed termination.
+func serverTerminationDue(server *hcloud.Server) (time.Time, error) {
+	ttl, err := serverTTL(server)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	retryInt, err := strconv.Atoi(server.Labels[LabelTerminationRetry])
+	if err != nil {
+		return ttl, nil
+	}
+
+	if retry := time.Unix(int64(retryInt), 0); retry.After(ttl) {
+		return retry, nil
+	}
+
+	return ttl, nil
+}
+
+// invalidTTLDue returns when a server whose ttl can not be read is terminated, right away or at the next retry
+// after a failed termination.
+func invalidTTLDue(server *hcloud.Server) time.Time {
+	retryInt, err := strconv.Atoi(server.Labels[LabelTerminationRetry])
+	if err != nil {
+		return time.Now()
+	}
+
+	return time.Unix(int64(retryInt), 0)
+}
+
+func serverTTL(server *hcloud.Server) (time.Time, error) {
+	ttlStr, ok := server.Labels[LabelTTL]
+	if !ok {
+		return time.Time{}, errors.New("ttl label missing")
+	}
+
+	ttlInt, err := strconv.Atoi(ttlStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse ttl: %s", err)
+	}
+
+	return time.Unix(int64(ttlInt), 0), nil
+}
@@ -0,0 +1,109 @@
+package control
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestServerTerminationDue(t *testing.T) {
+	ttl := time.Unix(1767225600, 0)
+
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		expected time.Time
+		err      bool
+	}{
+		{
+			name:     "ttl",
+			labels:   map[string]string{LabelTTL: strconv.FormatInt(ttl.Unix(), 10)},
+			expected: ttl,
+		},
+		{
+			name: "retry after ttl",
+			labels: map[string]string{
+				LabelTTL:              strconv.FormatInt(ttl.Unix(), 10),
+				LabelTerminationRetry: strconv.FormatInt(ttl.Add(time.Hour).Unix(), 10),
+			},
+			expected: ttl.Add(time.Hour),
+		},
+		{
+			// a server extended after a failed termination is due at its new ttl
+			name: "retry before ttl",
+			labels: map[string]string{
+				LabelTTL:              strconv.FormatInt(ttl.Unix(), 10),
+				LabelTerminationRetry: strconv.FormatInt(ttl.Add(-time.Hour).Unix(), 10),
+			},
+			expected: ttl,
+		},
+		{
+			name: "invalid retry",
+			labels: map[string]string{
+				LabelTTL:              strconv.FormatInt(ttl.Unix(), 10),
+				LabelTerminationRetry: "soon",
+			},
+			expected: ttl,
+		},
+		{name: "missing ttl", labels: map[string]string{}, err: true},
+		{name: "invalid ttl", labels: map[string]string{LabelTTL: "tomorrow"}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, err := serverTerminationDue(&hcloud.Server{Labels: tt.labels})
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", due)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !due.Equal(tt.expected) {
+				t.Errorf("expected %s, got %s", tt.expected, due)
+			}
+		})
+	}
+}
+
+func TestTerminationBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 1, expected: time.Minute},
+		{failures: 2, expected: 2 * time.Minute},
+		{failures: 3, expected: 4 * time.Minute},
+		{failures: 6, expected: 32 * time.Minute},
+		{failures: 7, expected: time.Hour},
+		{failures: 100, expected: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.failures), func(t *testing.T) {
+			backoff := terminationBackoff(tt.failures)
+			if backoff != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, backoff)
+			}
+		})
+	}
+}
+
+func TestInvalidTTLDue(t *testing.T) {
+	retry := time.Unix(1767225600, 0)
+
+	due := invalidTTLDue(&hcloud.Server{Labels: map[string]string{LabelTTL: "tomorrow", LabelTerminationRetry: strconv.FormatInt(retry.Unix(), 10)}})
+	if !due.Equal(retry) {
+		t.Errorf("expected the retry %s, got %s", retry, due)
+	}
+
+	due = invalidTTLDue(&hcloud.Server{Labels: map[string]string{LabelTTL: "tomorrow"}})
+	if time.Since(due) > time.Minute {
+		t.Errorf("expected the server to be due now, got %s", due)
+	}
+}
@@ -0,0 +1,50 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ServiceConfig holds the per-service settings loaded from the services file.
+type ServiceConfig struct {
+	RCON       *RCONConfig   `json:"rcon,omitempty"`
+	Readiness  *ProbeConfig  `json:"readiness,omitempty"`
+	Health     *HealthConfig `json:"health,omitempty"`
+	DNS        *DNSConfig    `json:"dns,omitempty"`
+	PrimaryIPs bool          `json:"primaryIPs,omitempty"`
+	IPv6Only   bool          `json:"ipv6Only,omitempty"`
+	Ports      []PortConfig  `json:"ports,omitempty"`
+	Volume     *VolumeConfig `json:"volume,omitempty"`
+	// Networks replace the globally configured networks for servers of the service
+	Networks []NetworkConfig `json:"networks,omitempty"`
+	// AllowDuration limits how long restricted ports stay open for an allowed ip, e.g. 2h
+	AllowDuration string `json:"allowDuration,omitempty"`
+	// Access restricts the service to the roles and users of the rules, admins are not restricted
+	Access []AccessRule `json:"access,omitempty"`
+}
+
+// LoadServiceConfigs reads the services file, a JSON object keyed by service name.
+func LoadServiceConfigs(path string) (map[string]*ServiceConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read services file: %s", err)
+	}
+
+	services := make(map[string]*ServiceConfig)
+
+	err = json.Unmarshal(data, &services)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse services file: %s", err)
+	}
+
+	return services, nil
+}
+
+func (control *Control) serviceConfig(serviceName string) *ServiceConfig {
+	if svc, ok := control.Config.Services[serviceName]; ok && svc != nil {
+		return svc
+	}
+
+	return &ServiceConfig{}
+}
@@ -0,0 +1,222 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// discord shows at most 25 choices
+	maxSlashChoices = 25
+)
+
+// slashCommands are the application commands of the bot, the server types and locations are offered
+// as autocompleted choices, as the allowed choices differ per member.
+var slashCommands = []*discordgo.ApplicationCommand{
+	{
+		Name:        "server",
+		Description: "Manage servers",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "new",
+				Description: "Create a new server",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "Name of the server", Required: true},
+					{Type: discordgo.ApplicationCommandOptionString, Name: "type", Description: "Server type", Autocomplete: true},
+					{Type: discordgo.ApplicationCommandOptionString, Name: "ttl", Description: "Time to live like 4h"},
+					{Type: discordgo.ApplicationCommandOptionString, Name: "location", Description: "Location", Autocomplete: true},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "type",
+				Description: "Change the type of a server",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "Name of the server", Required: true},
+					{Type: discordgo.ApplicationCommandOptionString, Name: "type", Description: "Server type", Required: true, Autocomplete: true},
+				},
+			},
+		},
+	},
+}
+
+// registerSlashCommands creates or edits the application commands in the configured guild,
+// other commands of the application in the guild are left alone.
+func (control *Control) registerSlashCommands() error {
+	appID := control.discordSession.State.User.ID
+
+	existing, err := control.discordSession.ApplicationCommands(appID, control.Config.DiscordGuildID)
+	if err != nil {
+		return fmt.Errorf("failed to list commands: %s", err)
+	}
+
+	for _, command := range slashCommands {
+		index := slices.IndexFunc(existing, func(existingCommand *discordgo.ApplicationCommand) bool {
+			return existingCommand.Name == command.Name
+		})
+
+		if index < 0 {
+			_, err = control.discordSession.ApplicationCommandCreate(appID, control.Config.DiscordGuildID, command)
+		} else {
+			_, err = control.discordSession.ApplicationCommandEdit(appID, control.Config.DiscordGuildID, existing[index].ID, command)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to register command %s: %s", command.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// slashCommandChoices returns the server types or locations the member may choose, starting with the typed prefix.
+func (control *Control) slashCommandChoices(member *discordgo.Member, optionName, prefix string) []*discordgo.ApplicationCommandOptionChoice {
+	var allowed []string
+
+	switch optionName {
+	case "type":
+		allowed = control.allowedServerTypes(member)
+	case "location":
+		allowed = control.allowedLocations(member)
+	default:
+		return nil
+	}
+
+	// members without restrictions get the choices of the catalog
+	if allowed == nil {
+		catalog, err := control.serverTypeCatalog(context.Background(), member.User.ID)
+		if err != nil {
+			log.Errorf("discord: failed to list server types: %s", err)
+			return nil
+		}
+
+		for _, entry := range catalog {
+			if optionName == "type" {
+				allowed = append(allowed, entry.Name)
+				continue
+			}
+
+			for _, price := range entry.Prices {
+				allowed = append(allowed, price.Location)
+			}
+		}
+
+		slices.Sort(allowed)
+		allowed = slices.Compact(allowed)
+	}
+
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+
+	for _, choice := range allowed {
+		if !strings.HasPrefix(choice, strings.ToLower(prefix)) {
+			continue
+		}
+
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: choice, Value: choice})
+
+		if len(choices) == maxSlashChoices {
+			break
+		}
+	}
+
+	return choices
+}
+
+func (control *Control) handleSlashCommandAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Member == nil {
+		return
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+
+	for _, subCommand := range i.ApplicationCommandData().Options {
+		for _, option := range subCommand.Options {
+			if option.Focused {
+				choices = control.slashCommandChoices(i.Member, option.Name, option.StringValue())
+			}
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		log.Errorf("discord: failed to respond to autocomplete of user %s: %s", i.Member.User.Username, err)
+	}
+}
+
+// handleSlashCommand runs the slash command as the equivalent bot command, so both share their checks and replies.
+func (control *Control) handleSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Member == nil || i.ChannelID != control.Config.DiscordChannelID {
+		respondEphemeral(s, i, "I only take commands in the server channel.")
+		return
+	}
+
+	data := i.ApplicationCommandData()
+	if data.Name != "server" {
+		respondEphemeral(s, i, fmt.Sprintf("I do not know the command /%s.", data.Name))
+		return
+	}
+	if len(data.Options) != 1 {
+		respondEphemeral(s, i, "Please choose one subcommand of /server.")
+		return
+	}
+
+	subCommand := data.Options[0]
+
+	options := make(map[string]string)
+	for _, option := range subCommand.Options {
+		options[option.Name] = option.StringValue()
+	}
+
+	args := []string{"!server", subCommand.Name, options["name"]}
+
+	switch subCommand.Name {
+	case "new":
+		// the positional arguments of the bot command need the defaults of the omitted options
+		if options["type"] == "" && (options["ttl"] != "" || options["location"] != "") {
+			options["type"] = "cx11"
+		}
+		if options["ttl"] == "" && options["location"] != "" {
+			options["ttl"] = "12h"
+		}
+		for _, name := range []string{"type", "ttl", "location"} {
+			if options[name] != "" {
+				args = append(args, options[name])
+			}
+		}
+	case "type":
+		args = append(args, options["type"])
+	default:
+		return
+	}
+
+	content := strings.Join(args, " ")
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	})
+	if err != nil {
+		log.Errorf("discord: failed to respond to slash command of user %s: %s", i.Member.User.Username, err)
+		return
+	}
+
+	control.handleDiscordCommand(i.Member, s, &discordgo.Message{
+		ID:        i.ID,
+		ChannelID: i.ChannelID,
+		GuildID:   i.GuildID,
+		Author:    i.Member.User,
+		Content:   content,
+	})
+}
@@ -0,0 +1,109 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// persistentState holds everything mnbcontrol has to remember which can not be kept in hcloud labels.
+type persistentState struct {
+	Sessions []*Session `json:"sessions,omitempty"`
+	// BudgetWarnings holds the highest warned threshold per month and budget
+	BudgetWarnings map[string]int     `json:"budgetWarnings,omitempty"`
+	StartQueue     []*QueuedStart     `json:"startQueue,omitempty"`
+	Approvals      []*ApprovalRequest `json:"approvals,omitempty"`
+	Schedules      []*Schedule        `json:"schedules,omitempty"`
+	Polls          []*Poll            `json:"polls,omitempty"`
+	// EventStarts holds the service started per guild scheduled event
+	EventStarts map[string]string `json:"eventStarts,omitempty"`
+	// DNSRecords holds the records created per service, so the config might change while the server runs
+	DNSRecords map[string][]DNSRecordRef `json:"dnsRecords,omitempty"`
+}
+
+// stateStore keeps the persistent state in a JSON file, an empty path keeps it in memory only.
+type stateStore struct {
+	path  string
+	mutex sync.Mutex
+	state persistentState
+}
+
+func loadStateStore(path string) (*stateStore, error) {
+	store := &stateStore{path: path}
+
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %s", err)
+	}
+
+	err = json.Unmarshal(data, &store.state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %s", err)
+	}
+
+	return store, nil
+}
+
+// view calls fn with the state locked, fn must not modify the state.
+func (store *stateStore) view(fn func(state *persistentState)) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	fn(&store.state)
+}
+
+// update calls fn with the state locked and saves the state afterwards.
+func (store *stateStore) update(fn func(state *persistentState)) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	fn(&store.state)
+
+	return store.save()
+}
+
+// save writes the state to a temporary file first, so a crash never leaves a truncated state file behind.
+func (store *stateStore) save() error {
+	if store.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(store.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %s", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %s", err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %s", err)
+	}
+
+	err = os.Rename(tmp.Name(), store.path)
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %s", err)
+	}
+
+	return nil
+}
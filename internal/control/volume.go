@@ -0,0 +1,298 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	VolumeFormatExt4 = "ext4"
+	VolumeFormatXFS  = "xfs"
+
+	defaultVolumeMountPath = "/srv/data"
+)
+
+// VolumeConfig keeps the data of the service on a volume instead of snapshotting the server on stop, size is in GB.
+type VolumeConfig struct {
+	Size      int    `json:"size"`
+	MountPath string `json:"mountPath,omitempty"`
+	Format    string `json:"format,omitempty"`
+}
+
+type ResizeVolumeRequest struct {
+	ServerName string `json:"serverName"`
+	Size       int    `json:"size"`
+}
+
+func (control *Control) volumeName(serviceName string) string {
+	return "mnbcontrol-" + serviceName
+}
+
+func (control *Control) getVolume(ctx context.Context, serviceName string) (*hcloud.Volume, error) {
+	volume, _, err := control.hclient.Volume.GetByName(ctx, control.volumeName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volume of service %s: %s", serviceName, err)
+	}
+
+	if volume != nil && volume.Labels[LabelManagedBy] != LabelValueMangedByControl {
+		return nil, fmt.Errorf("volume %s is not managed by mnbcontrol", volume.Name)
+	}
+
+	return volume, nil
+}
+
+// ensureVolume creates the volume of the service on its first start, it is protected against deletion like snapshots.
+func (control *Control) ensureVolume(ctx context.Context, serviceName, serverType string, location *hcloud.Location) (*hcloud.Volume, error) {
+	volume, err := control.getVolume(ctx, serviceName)
+	if err != nil || volume != nil {
+		return volume, err
+	}
+
+	volumeConfig := control.serviceConfig(serviceName).Volume
+
+	format := volumeConfig.Format
+	if format == "" {
+		format = VolumeFormatExt4
+	}
+
+	result, _, err := control.hclient.Volume.Create(ctx, hcloud.VolumeCreateOpts{
+		Name:     control.volumeName(serviceName),
+		Size:     volumeConfig.Size,
+		Location: location,
+		Format:   new(format),
+		Labels: map[string]string{
+			LabelManagedBy:  LabelValueMangedByControl,
+			LabelService:    serviceName,
+			LabelServerType: serverType,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create volume for service %s: %s", serviceName, err)
+	}
+
+	err = control.hclient.Action.WaitFor(ctx, append(result.NextActions, result.Action)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create volume for service %s: %s", serviceName, err)
+	}
+
+	err = control.changeVolumeProtection(ctx, result.Volume, true)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("created volume %s for service %s", result.Volume.Name, serviceName)
+
+	return result.Volume, nil
+}
+
+// serviceVolumes returns the volumes to attach when creating a server of the service
+// together with the cloud-init user data mounting them.
+func (control *Control) serviceVolumes(ctx context.Context, serviceName, serverType string, location *hcloud.Location) ([]*hcloud.Volume, string, error) {
+	volumeConfig := control.serviceConfig(serviceName).Volume
+	if volumeConfig == nil {
+		return nil, "", nil
+	}
+
+	volume, err := control.ensureVolume(ctx, serviceName, serverType, location)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if volume.Location != nil && volume.Location.Name != location.Name {
+		return nil, "", fmt.Errorf("volume %s is in location %s, not %s", volume.Name, volume.Location.Name, location.Name)
+	}
+
+	if volume.Server != nil {
+		return nil, "", fmt.Errorf("volume %s is still attached to server %d", volume.Name, volume.Server.ID)
+	}
+
+	return []*hcloud.Volume{volume}, volumeUserData(volume, volumeConfig), nil
+}
+
+// volumeUserData mounts the volume and grows its filesystem in case it was resized while detached.
+func volumeUserData(volume *hcloud.Volume, volumeConfig *VolumeConfig) string {
+	mountPath := volumeConfig.MountPath
+	if mountPath == "" {
+		mountPath = defaultVolumeMountPath
+	}
+
+	format := volumeConfig.Format
+	if format == "" {
+		format = VolumeFormatExt4
+	}
+
+	growCommand := fmt.Sprintf("resize2fs %s", volume.LinuxDevice)
+	if format == VolumeFormatXFS {
+		growCommand = fmt.Sprintf("xfs_growfs %s", mountPath)
+	}
+
+	return fmt.Sprintf(`#cloud-config
+mounts:
+  - [%s, %s, %s, "discard,nofail,defaults", "0", "0"]
+runcmd:
+  - [sh, -c, "%s"]
+`, volume.LinuxDevice, mountPath, format, growCommand)
+}
+
+// volumeStartImage returns the image and server type for starting a volume service, which always starts from the blueprint.
+func (control *Control) volumeStartImage(ctx context.Context, serviceName string) (*hcloud.Image, string, error) {
+	volume, err := control.getVolume(ctx, serviceName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if volume == nil {
+		return nil, "", fmt.Errorf("unable to find volume for server %s", serviceName)
+	}
+
+	blueprintImage, err := control.activeBlueprint(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to find active blueprint image for server %s: %s", serviceName, err)
+	}
+
+	return blueprintImage, volume.Labels[LabelServerType], nil
+}
+
+// detachVolume detaches the volume from the stopped server and remembers the server type and owner for the next start.
+func (control *Control) detachVolume(ctx context.Context, server *hcloud.Server) error {
+	volume, err := control.getVolume(ctx, server.Name)
+	if err != nil {
+		return err
+	}
+
+	if volume == nil {
+		return fmt.Errorf("unable to find volume for server %s", server.Name)
+	}
+
+	volume.Labels[LabelServerType] = server.ServerType.Name
+	volume.Labels[LabelOwner] = server.Labels[LabelOwner]
+
+	_, _, err = control.hclient.Volume.Update(ctx, volume, hcloud.VolumeUpdateOpts{Labels: volume.Labels})
+	if err != nil {
+		return fmt.Errorf("failed to update volume of server %s: %s", server.Name, err)
+	}
+
+	if volume.Server == nil {
+		return nil
+	}
+
+	action, _, err := control.hclient.Volume.Detach(ctx, volume)
+	if err != nil {
+		return fmt.Errorf("failed to detach volume of server %s: %s", server.Name, err)
+	}
+
+	err = control.hclient.Action.WaitFor(ctx, action)
+	if err != nil {
+		return fmt.Errorf("failed to detach volume of server %s: %s", server.Name, err)
+	}
+
+	log.Infof("detached volume %s from server %s", volume.Name, server.Name)
+
+	return nil
+}
+
+func (control *Control) changeVolumeServerType(ctx context.Context, req ChangeServerTypeRequest) error {
+	volume, err := control.getVolume(ctx, req.ServerName)
+	if err != nil {
+		return err
+	}
+
+	if volume == nil {
+		return fmt.Errorf("volume for server %s not found", req.ServerName)
+	}
+
+	volume.Labels[LabelServerType] = req.ServerType
+
+	_, _, err = control.hclient.Volume.Update(ctx, volume, hcloud.VolumeUpdateOpts{Labels: volume.Labels})
+	if err != nil {
+		return fmt.Errorf("failed to update volume for server %s: %s", req.ServerName, err)
+	}
+
+	return nil
+}
+
+// resizeVolume grows the volume of the service, the filesystem is grown on the next start of the server.
+func (control *Control) resizeVolume(ctx context.Context, req ResizeVolumeRequest) error {
+	volume, err := control.getVolume(ctx, req.ServerName)
+	if err != nil {
+		return err
+	}
+
+	if volume == nil {
+		return fmt.Errorf("unable to find volume for server %s", req.ServerName)
+	}
+
+	if req.Size <= volume.Size {
+		return fmt.Errorf("volumes can only grow, current size is %d GB", volume.Size)
+	}
+
+	action, _, err := control.hclient.Volume.Resize(ctx, volume, req.Size)
+	if err != nil {
+		return fmt.Errorf("failed to resize volume of server %s: %s", req.ServerName, err)
+	}
+
+	err = control.hclient.Action.WaitFor(ctx, action)
+	if err != nil {
+		return fmt.Errorf("failed to resize volume of server %s: %s", req.ServerName, err)
+	}
+
+	log.Infof("resized volume %s to %d GB", volume.Name, req.Size)
+
+	return nil
+}
+
+func (control *Control) deleteVolume(ctx context.Context, serviceName string) error {
+	volume, err := control.getVolume(ctx, serviceName)
+	if err != nil || volume == nil {
+		return err
+	}
+
+	if volume.Server != nil {
+		return errors.New("can't delete a volume attached to a server")
+	}
+
+	err = control.changeVolumeProtection(ctx, volume, false)
+	if err != nil {
+		return err
+	}
+
+	_, err = control.hclient.Volume.Delete(ctx, volume)
+	if err != nil {
+		return fmt.Errorf("failed to delete volume of service %s: %s", serviceName, err)
+	}
+
+	log.Infof("deleted volume %s of service %s", volume.Name, serviceName)
+
+	return nil
+}
+
+func (control *Control) listVolumes(ctx context.Context) ([]*hcloud.Volume, error) {
+	volumes, err := control.hclient.Volume.AllWithOpts(ctx, hcloud.VolumeListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: fmt.Sprintf("%s=%s", LabelManagedBy, LabelValueMangedByControl)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %s", err)
+	}
+
+	return volumes, nil
+}
+
+func (control *Control) changeVolumeProtection(ctx context.Context, volume *hcloud.Volume, protect bool) error {
+	action, _, err := control.hclient.Volume.ChangeProtection(ctx, volume, hcloud.VolumeChangeProtectionOpts{
+		Delete: new(protect),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to change protection of volume %s: %s", volume.Name, err)
+	}
+
+	err = control.hclient.Action.WaitFor(ctx, action)
+	if err != nil {
+		return fmt.Errorf("failed to change protection of volume %s: %s", volume.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,350 @@
+package control
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	voteButtonPrefix    = "vote:"
+	voteCleanupInterval = time.Minute
+)
+
+// Poll collects the votes for starting or extending a server.
+type Poll struct {
+	ID         string    `json:"id"`
+	Action     string    `json:"action"`
+	ServerName string    `json:"serverName"`
+	TTL        string    `json:"ttl"`
+	StartedBy  string    `json:"startedBy"`
+	ChannelID  string    `json:"channelID"`
+	MessageID  string    `json:"messageID"`
+	Expires    time.Time `json:"expires"`
+	Voters     []string  `json:"voters"`
+}
+
+// vote records the vote of the user and reports whether the poll reached the quorum with it,
+// a poll which reached its quorum is removed so its action only runs once.
+func (control *Control) vote(id, userID string, quorum int) (Poll, int, bool, error) {
+	var voted Poll
+	var voteErr error
+
+	passed := false
+	now := time.Now()
+
+	err := control.state.update(func(state *persistentState) {
+		index := slices.IndexFunc(state.Polls, func(p *Poll) bool {
+			return p.ID == id
+		})
+		if index < 0 || now.After(state.Polls[index].Expires) {
+			voteErr = errors.New("this vote has ended")
+			return
+		}
+
+		running := state.Polls[index]
+
+		if slices.Contains(running.Voters, userID) {
+			voteErr = errors.New("you have already voted")
+			return
+		}
+
+		running.Voters = append(running.Voters, userID)
+		voted = *running
+
+		if len(running.Voters) < quorum {
+			return
+		}
+
+		passed = true
+		state.Polls = slices.Delete(state.Polls, index, index+1)
+	})
+	if err != nil {
+		return Poll{}, 0, false, fmt.Errorf("failed to save vote: %s", err)
+	}
+	if voteErr != nil {
+		return Poll{}, 0, false, voteErr
+	}
+
+	return voted, len(voted.Voters), passed, nil
+}
+
+func (control *Control) handleVoteCommand(member *discordgo.Member, s *discordgo.Session, m *discordgo.Message) error {
+	if !memberHasRole(member, control.Config.DiscordAdminRoleID, control.Config.DiscordPowerUserRoleID, control.Config.DiscordUserRoleID) {
+		return ErrUnauthorized
+	}
+	if control.Config.VoteQuorum <= 0 {
+		return fmt.Errorf("failed to start vote for bot: %w", fmt.Errorf("%w: voting is disabled", ErrNotAllowed))
+	}
+	contentSplit := strings.Split(strings.ToLower(m.Content), " ")
+	if len(contentSplit) < 3 {
+		return ErrIllegalArguments
+	}
+	p := &Poll{
+		Action:    contentSplit[1],
+		StartedBy: m.Author.ID,
+		ChannelID: m.ChannelID,
+		Expires:   time.Now().Add(control.Config.VoteWindow),
+	}
+	switch {
+	case p.Action == ActionStart && len(contentSplit) == 3:
+		p.ServerName, p.TTL = contentSplit[2], "12h"
+	case p.Action == ActionStart && len(contentSplit) == 4:
+		p.ServerName, p.TTL = contentSplit[2], contentSplit[3]
+	case p.Action == ActionExtend && len(contentSplit) == 4:
+		p.ServerName, p.TTL = contentSplit[2], contentSplit[3]
+	default:
+		return ErrIllegalArguments
+	}
+	_, err := time.ParseDuration(p.TTL)
+	if err != nil {
+		return ErrIllegalArguments
+	}
+	err = control.checkAccess(member, p.ServerName, p.Action)
+	if err != nil {
+		return fmt.Errorf("failed to start vote for bot: %w", err)
+	}
+	// the server is extended on behalf of the member who started the vote, so they have to be allowed to
+	if p.Action == ActionExtend {
+		err = control.checkServerOwner(context.Background(), p.StartedBy, p.ServerName)
+		if err != nil {
+			return fmt.Errorf("failed to start vote for bot: %w", err)
+		}
+	}
+
+	buf := make([]byte, 16)
+	_, err = rand.Read(buf)
+	if err != nil {
+		return fmt.Errorf("failed to create vote for bot: %s", err)
+	}
+	p.ID = hex.EncodeToString(buf)
+
+	running := false
+
+	// the poll is added together with the check, so there is only one vote per server and action
+	err = control.state.update(func(state *persistentState) {
+		running = slices.ContainsFunc(state.Polls, func(other *Poll) bool {
+			return other.ServerName == p.ServerName && other.Action == p.Action && time.Now().Before(other.Expires)
+		})
+		if !running {
+			state.Polls = append(state.Polls, p)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save vote: %s", err)
+	}
+
+	if running {
+		return fmt.Errorf("failed to start vote for bot: %w", fmt.Errorf("%w: a vote to %s server %s is already running", ErrNotAllowed, p.Action, p.ServerName))
+	}
+
+	msg, sendErr := s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+		Content: fmt.Sprintf(
+			"<@%s> wants to %s server %s for %s. %d votes within %s are needed.",
+			p.StartedBy,
+			p.Action,
+			p.ServerName,
+			p.TTL,
+			control.Config.VoteQuorum,
+			control.Config.VoteWindow,
+		),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{Label: "Vote", Style: discordgo.PrimaryButton, CustomID: voteButtonPrefix + p.ID},
+				},
+			},
+		},
+	})
+
+	// a poll without its message can never pass, so it is removed again
+	err = control.state.update(func(state *persistentState) {
+		if sendErr != nil {
+			state.Polls = slices.DeleteFunc(state.Polls, func(other *Poll) bool {
+				return other.ID == p.ID
+			})
+			return
+		}
+
+		for _, other := range state.Polls {
+			if other.ID == p.ID {
+				other.MessageID = msg.ID
+			}
+		}
+	})
+	if sendErr != nil {
+		return fmt.Errorf("discord: failed to reply to user %s: %s", m.Author.Username, sendErr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save vote: %s", err)
+	}
+
+	return nil
+}
+
+// handleVoteInteraction counts a click on the vote button, the quorum is reached among the members with
+// the user role who may see the server, admins and power users only count if they have the user role too.
+func (control *Control) handleVoteInteraction(s *discordgo.Session, i *discordgo.InteractionCreate, id string) {
+	if i.Member == nil {
+		return
+	}
+
+	if !memberHasRole(i.Member, control.Config.DiscordUserRoleID) {
+		respondEphemeral(s, i, "Only members with the user role can vote.")
+		return
+	}
+
+	var serverName string
+
+	control.state.view(func(state *persistentState) {
+		for _, p := range state.Polls {
+			if p.ID == id {
+				serverName = p.ServerName
+			}
+		}
+	})
+
+	if serverName != "" && !control.memberMayAccess(i.Member, serverName, ActionView) {
+		respondEphemeral(s, i, "You are not allowed to vote.")
+		return
+	}
+
+	p, votes, passed, err := control.vote(id, i.Member.User.ID, control.Config.VoteQuorum)
+	if err != nil {
+		respondEphemeral(s, i, fmt.Sprintf("I'm sorry, Dave. I'm afraid I can't do that: %s", err))
+		return
+	}
+
+	data := &discordgo.InteractionResponseData{
+		Content: fmt.Sprintf("%s\n%d of %d votes.", strings.Split(i.Message.Content, "\n")[0], votes, control.Config.VoteQuorum),
+	}
+	if passed {
+		// the button is removed so the action can not run twice
+		data.Content = fmt.Sprintf("%s\nThe vote passed with %d votes.", strings.Split(i.Message.Content, "\n")[0], votes)
+		data.Components = []discordgo.MessageComponent{}
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: data,
+	})
+	if err != nil {
+		log.Errorf("discord: failed to respond to interaction of user %s: %s", i.Member.User.Username, err)
+	}
+
+	if !passed {
+		return
+	}
+
+	control.audit(p.StartedBy, "vote "+p.Action, p.ServerName, fmt.Sprintf("%d votes", votes))
+
+	go control.runPoll(context.Background(), s, p)
+}
+
+// runPoll runs the action of a passed poll on behalf of the member who started the vote.
+func (control *Control) runPoll(ctx context.Context, s *discordgo.Session, p Poll) {
+	announce := func(msg string) {
+		_, err := s.ChannelMessageSend(p.ChannelID, msg)
+		if err != nil {
+			log.Errorf("discord: failed to announce vote result of server %s: %s", p.ServerName, err)
+		}
+	}
+
+	switch p.Action {
+	case ActionStart:
+		server, err := control.startServer(ctx, p.StartedBy, StartServerRequest{
+			ServerName: p.ServerName,
+			TTL:        p.TTL,
+			Confirm:    true,
+		})
+		var queuedErr *QueuedError
+		switch {
+		case errors.As(err, &queuedErr):
+			announce(fmt.Sprintf("%s.", queuedErr))
+		case isVoteRefusal(err):
+			announce(fmt.Sprintf("The vote passed, but server %s could not be started: %s", p.ServerName, err))
+		case err != nil:
+			log.Errorf("vote error: failed to start server %s: %s", p.ServerName, err)
+			announce(fmt.Sprintf("The vote passed, but server %s could not be started.", p.ServerName))
+		default:
+			control.announceWhenReady(server, announce, fmt.Sprintf("Server %s is ready with DNS %s. It will run for %s", server.Name, serverDNSPtr(server), p.TTL))
+		}
+	case ActionExtend:
+		// the owner might have changed while the vote was running
+		err := control.checkServerOwner(ctx, p.StartedBy, p.ServerName)
+
+		var extendedTTL *time.Time
+		if err == nil {
+			extendedTTL, err = control.extendServer(ctx, p.StartedBy, ExtendServerRequest{
+				ServerName: p.ServerName,
+				TTL:        p.TTL,
+			})
+		}
+
+		switch {
+		case isVoteRefusal(err):
+			announce(fmt.Sprintf("The vote passed, but server %s could not be extended: %s", p.ServerName, err))
+		case err != nil:
+			log.Errorf("vote error: failed to extend server %s: %s", p.ServerName, err)
+			announce(fmt.Sprintf("The vote passed, but server %s could not be extended.", p.ServerName))
+		default:
+			announce(fmt.Sprintf("Server %s has been extended until %s", p.ServerName, extendedTTL.Format(time.RFC3339)))
+		}
+	}
+}
+
+// isVoteRefusal reports whether the action of a passed poll was refused by a check, which is shown to the voters.
+func isVoteRefusal(err error) bool {
+	return errors.Is(err, ErrBudgetExceeded) || errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrNotOwner) || errors.Is(err, ErrNotAllowed)
+}
+
+// expirePolls ends the polls which did not reach their quorum in time, removing their buttons.
+func (control *Control) expirePolls() {
+	var expired []*Poll
+
+	now := time.Now()
+
+	err := control.state.update(func(state *persistentState) {
+		state.Polls = slices.DeleteFunc(state.Polls, func(p *Poll) bool {
+			if now.Before(p.Expires) {
+				return false
+			}
+
+			copied := *p
+			expired = append(expired, &copied)
+
+			return true
+		})
+	})
+	if err != nil {
+		log.Errorf("vote error: failed to save votes: %s", err)
+	}
+
+	for _, p := range expired {
+		log.Infof("vote: vote to %s server %s ended with %d votes", p.Action, p.ServerName, len(p.Voters))
+
+		_, err := control.discordSession.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:      p.MessageID,
+			Channel: p.ChannelID,
+			Content: new(fmt.Sprintf(
+				"The vote to %s server %s ended with %d of %d votes.",
+				p.Action,
+				p.ServerName,
+				len(p.Voters),
+				control.Config.VoteQuorum,
+			)),
+			Components: &[]discordgo.MessageComponent{},
+		})
+		if err != nil {
+			log.Errorf("discord: failed to end vote of server %s: %s", p.ServerName, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package control
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVote(t *testing.T) {
+	control := &Control{state: &stateStore{state: persistentState{Polls: []*Poll{
+		{ID: "running", Action: ActionStart, ServerName: "minecraft", Expires: time.Now().Add(time.Hour)},
+		{ID: "expired", Action: ActionStart, ServerName: "valheim", Expires: time.Now().Add(-time.Minute)},
+	}}}}
+
+	tests := []struct {
+		name   string
+		id     string
+		userID string
+		votes  int
+		passed bool
+		err    bool
+	}{
+		{name: "first vote", id: "running", userID: "alice", votes: 1},
+		{name: "voted twice", id: "running", userID: "alice", err: true},
+		{name: "quorum", id: "running", userID: "bob", votes: 2, passed: true},
+		// a passed poll is removed, so its action runs only once
+		{name: "passed", id: "running", userID: "carol", err: true},
+		{name: "expired", id: "expired", userID: "alice", err: true},
+		{name: "unknown", id: "unknown", userID: "alice", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, votes, passed, err := control.vote(tt.id, tt.userID, 2)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if votes != tt.votes || passed != tt.passed {
+				t.Errorf("expected %d votes and passed %t, got %d and %t", tt.votes, tt.passed, votes, passed)
+			}
+		})
+	}
+}
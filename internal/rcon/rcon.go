@@ -0,0 +1,231 @@
+package rcon
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+type Protocol string
+
+const (
+	ProtocolSource    Protocol = "source"
+	ProtocolMinecraft Protocol = "minecraft"
+)
+
+const (
+	packetTypeResponseValue int32 = 0
+	packetTypeExecCommand   int32 = 2
+	packetTypeAuthResponse  int32 = 2
+	packetTypeAuth          int32 = 3
+
+	// minecraft splits responses into fragments of at most 4096 bytes
+	minecraftMaxFragment = 4096
+	maxPacketSize        = 4096 + 10
+)
+
+var (
+	ErrAuthFailed      = errors.New("rcon authentication failed")
+	ErrInvalidResponse = errors.New("rcon invalid response")
+)
+
+type Client struct {
+	conn     net.Conn
+	protocol Protocol
+	timeout  time.Duration
+	nextID   int32
+}
+
+type packet struct {
+	ID   int32
+	Type int32
+	Body string
+}
+
+// Dial connects to the rcon server at addr and authenticates with the given password.
+func Dial(ctx context.Context, addr, password string, protocol Protocol, timeout time.Duration) (*Client, error) {
+	switch protocol {
+	case "":
+		protocol = ProtocolSource
+	case ProtocolSource, ProtocolMinecraft:
+	default:
+		return nil, fmt.Errorf("unknown rcon protocol %s", protocol)
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %s", addr, err)
+	}
+
+	client := &Client{
+		conn:     conn,
+		protocol: protocol,
+		timeout:  timeout,
+		nextID:   1,
+	}
+
+	err = client.auth(password)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Execute runs the command on the server and returns its output.
+func (c *Client) Execute(command string) (string, error) {
+	id, err := c.write(packetTypeExecCommand, command)
+	if err != nil {
+		return "", err
+	}
+
+	if c.protocol == ProtocolMinecraft {
+		return c.readMinecraftResponse(id)
+	}
+
+	// source servers mirror an empty response value packet after the
+	// command output, which marks the end of a multi packet response
+	terminatorID, err := c.write(packetTypeResponseValue, "")
+	if err != nil {
+		return "", err
+	}
+
+	var output bytes.Buffer
+
+	for {
+		p, err := c.read()
+		if err != nil {
+			return "", err
+		}
+
+		switch p.ID {
+		case id:
+			output.WriteString(p.Body)
+		case terminatorID:
+			return output.String(), nil
+		default:
+			return "", ErrInvalidResponse
+		}
+	}
+}
+
+func (c *Client) auth(password string) error {
+	id, err := c.write(packetTypeAuth, password)
+	if err != nil {
+		return err
+	}
+
+	for {
+		p, err := c.read()
+		if err != nil {
+			return err
+		}
+
+		// source servers send an empty response value before the auth response
+		if p.Type == packetTypeResponseValue && c.protocol == ProtocolSource {
+			continue
+		}
+
+		if p.Type != packetTypeAuthResponse {
+			return ErrInvalidResponse
+		}
+
+		if p.ID == -1 || p.ID != id {
+			return ErrAuthFailed
+		}
+
+		return nil
+	}
+}
+
+func (c *Client) readMinecraftResponse(id int32) (string, error) {
+	var output bytes.Buffer
+
+	for {
+		p, err := c.read()
+		if err != nil {
+			return "", err
+		}
+
+		if p.ID != id {
+			return "", ErrInvalidResponse
+		}
+
+		output.WriteString(p.Body)
+
+		if len(p.Body) < minecraftMaxFragment {
+			return output.String(), nil
+		}
+	}
+}
+
+func (c *Client) write(packetType int32, body string) (int32, error) {
+	id := c.nextID
+	c.nextID++
+
+	// size covers id, type, body and the two null terminators
+	size := int32(4 + 4 + len(body) + 2)
+
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, binary.LittleEndian, size)
+	_ = binary.Write(&buf, binary.LittleEndian, id)
+	_ = binary.Write(&buf, binary.LittleEndian, packetType)
+	buf.WriteString(body)
+	buf.Write([]byte{0, 0})
+
+	err := c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = c.conn.Write(buf.Bytes())
+	if err != nil {
+		return 0, fmt.Errorf("failed to write rcon packet: %s", err)
+	}
+
+	return id, nil
+}
+
+func (c *Client) read() (*packet, error) {
+	err := c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	if err != nil {
+		return nil, err
+	}
+
+	var size int32
+
+	err = binary.Read(c.conn, binary.LittleEndian, &size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rcon packet: %s", err)
+	}
+
+	if size < 10 || size > maxPacketSize {
+		return nil, ErrInvalidResponse
+	}
+
+	data := make([]byte, size)
+
+	_, err = io.ReadFull(c.conn, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rcon packet: %s", err)
+	}
+
+	return &packet{
+		ID:   int32(binary.LittleEndian.Uint32(data[0:4])),
+		Type: int32(binary.LittleEndian.Uint32(data[4:8])),
+		Body: string(bytes.TrimRight(data[8:], "\x00")),
+	}, nil
+}
@@ -0,0 +1,299 @@
+package rcon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTimeout = time.Second
+
+// encodePacket returns the wire format of a packet, size, id and type are little endian
+// and the body is followed by two null bytes.
+func encodePacket(id, packetType int32, body string) []byte {
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, binary.LittleEndian, int32(4+4+len(body)+2))
+	_ = binary.Write(&buf, binary.LittleEndian, id)
+	_ = binary.Write(&buf, binary.LittleEndian, packetType)
+	buf.WriteString(body)
+	buf.Write([]byte{0, 0})
+
+	return buf.Bytes()
+}
+
+// newTestClient returns a client connected to a fake server, the server side runs the handler.
+func newTestClient(t *testing.T, protocol Protocol, handler func(server *Client)) *Client {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer serverConn.Close()
+
+		handler(&Client{conn: serverConn, timeout: testTimeout})
+	}()
+
+	t.Cleanup(func() {
+		_ = clientConn.Close()
+		<-done
+	})
+
+	return &Client{conn: clientConn, protocol: protocol, timeout: testTimeout, nextID: 1}
+}
+
+func reply(server *Client, id, packetType int32, body string) {
+	_ = server.conn.SetWriteDeadline(time.Now().Add(testTimeout))
+	_, _ = server.conn.Write(encodePacket(id, packetType, body))
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name       string
+		packetType int32
+		body       string
+	}{
+		{name: "auth", packetType: packetTypeAuth, body: "secret"},
+		{name: "command", packetType: packetTypeExecCommand, body: "list"},
+		{name: "empty response value", packetType: packetTypeResponseValue, body: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected := encodePacket(1, tt.packetType, tt.body)
+			receivedChan := make(chan []byte, 1)
+
+			client := newTestClient(t, ProtocolSource, func(server *Client) {
+				received := make([]byte, len(expected))
+				_, _ = io.ReadFull(server.conn, received)
+				receivedChan <- received
+			})
+
+			id, err := client.write(tt.packetType, tt.body)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if id != 1 {
+				t.Errorf("expected id 1, got %d", id)
+			}
+
+			if received := <-receivedChan; !bytes.Equal(received, expected) {
+				t.Errorf("expected %x, got %x", expected, received)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	tooLarge := make([]byte, 4)
+	binary.LittleEndian.PutUint32(tooLarge, uint32(maxPacketSize+1))
+
+	tooSmall := make([]byte, 4)
+	binary.LittleEndian.PutUint32(tooSmall, 9)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected *packet
+		err      error
+	}{
+		{
+			name:     "response",
+			data:     encodePacket(7, packetTypeResponseValue, "There are 0 players online"),
+			expected: &packet{ID: 7, Type: packetTypeResponseValue, Body: "There are 0 players online"},
+		},
+		{
+			name:     "empty body",
+			data:     encodePacket(-1, packetTypeAuthResponse, ""),
+			expected: &packet{ID: -1, Type: packetTypeAuthResponse},
+		},
+		{name: "too large", data: tooLarge, err: ErrInvalidResponse},
+		{name: "too small", data: tooSmall, err: ErrInvalidResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, ProtocolSource, func(server *Client) {
+				_ = server.conn.SetWriteDeadline(time.Now().Add(testTimeout))
+				_, _ = server.conn.Write(tt.data)
+			})
+
+			p, err := client.read()
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if *p != *tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, p)
+			}
+		})
+	}
+}
+
+func TestAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol Protocol
+		replies  func(server *Client, id int32)
+		err      error
+	}{
+		{
+			name:     "source",
+			protocol: ProtocolSource,
+			replies: func(server *Client, id int32) {
+				reply(server, id, packetTypeResponseValue, "")
+				reply(server, id, packetTypeAuthResponse, "")
+			},
+		},
+		{
+			name:     "minecraft",
+			protocol: ProtocolMinecraft,
+			replies: func(server *Client, id int32) {
+				reply(server, id, packetTypeAuthResponse, "")
+			},
+		},
+		{
+			name:     "wrong password",
+			protocol: ProtocolSource,
+			replies: func(server *Client, id int32) {
+				reply(server, id, packetTypeResponseValue, "")
+				reply(server, -1, packetTypeAuthResponse, "")
+			},
+			err: ErrAuthFailed,
+		},
+		{
+			name:     "unexpected packet",
+			protocol: ProtocolMinecraft,
+			replies: func(server *Client, id int32) {
+				reply(server, id, packetTypeResponseValue, "")
+			},
+			err: ErrInvalidResponse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, tt.protocol, func(server *Client) {
+				p, err := server.read()
+				if err != nil || p.Type != packetTypeAuth || p.Body != "secret" {
+					return
+				}
+
+				tt.replies(server, p.ID)
+			})
+
+			err := client.auth("secret")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestExecuteSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		fragments []string
+		foreignID bool
+		expected  string
+		err       error
+	}{
+		{name: "single packet", fragments: []string{"hostname: test"}, expected: "hostname: test"},
+		{name: "multi packet", fragments: []string{strings.Repeat("a", 4000), strings.Repeat("b", 100)}, expected: strings.Repeat("a", 4000) + strings.Repeat("b", 100)},
+		{name: "no output", expected: ""},
+		{name: "unexpected id", fragments: []string{"output"}, foreignID: true, err: ErrInvalidResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, ProtocolSource, func(server *Client) {
+				command, err := server.read()
+				if err != nil || command.Type != packetTypeExecCommand || command.Body != "status" {
+					return
+				}
+
+				// the empty response value is mirrored after the output of the command
+				terminator, err := server.read()
+				if err != nil || terminator.Type != packetTypeResponseValue || terminator.Body != "" {
+					return
+				}
+
+				for _, fragment := range tt.fragments {
+					id := command.ID
+					if tt.foreignID {
+						id = command.ID + 100
+					}
+					reply(server, id, packetTypeResponseValue, fragment)
+				}
+
+				reply(server, terminator.ID, packetTypeResponseValue, "")
+			})
+
+			output, err := client.Execute("status")
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if output != tt.expected {
+				t.Errorf("expected output of %d bytes, got %d bytes", len(tt.expected), len(output))
+			}
+		})
+	}
+}
+
+func TestExecuteMinecraft(t *testing.T) {
+	tests := []struct {
+		name      string
+		fragments []string
+		expected  string
+	}{
+		{name: "single fragment", fragments: []string{"There are 0 of a max of 20 players online"}, expected: "There are 0 of a max of 20 players online"},
+		{name: "split response", fragments: []string{strings.Repeat("a", minecraftMaxFragment), "b"}, expected: strings.Repeat("a", minecraftMaxFragment) + "b"},
+		{name: "no output", fragments: []string{""}, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, ProtocolMinecraft, func(server *Client) {
+				command, err := server.read()
+				if err != nil || command.Type != packetTypeExecCommand {
+					return
+				}
+
+				for _, fragment := range tt.fragments {
+					reply(server, command.ID, packetTypeResponseValue, fragment)
+				}
+			})
+
+			output, err := client.Execute("list")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if output != tt.expected {
+				t.Errorf("expected output of %d bytes, got %d bytes", len(tt.expected), len(output))
+			}
+		})
+	}
+}